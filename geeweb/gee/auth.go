@@ -0,0 +1,282 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the authentication middlewares store the principal under UserKey, the
+//Logger prints it, and JWT stores the verified claims under ClaimsKey
+const (
+	UserKey   = "user"
+	ClaimsKey = "claims"
+)
+
+//Accounts maps user names to passwords for BasicAuth
+type Accounts map[string]string
+
+//BasicAuth checks HTTP basic credentials against accounts
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "Authorization Required")
+}
+
+//BasicAuthForRealm is BasicAuth with the realm shown by browsers
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	//compare digests so that the time taken doesn't tell the password length
+	digests := make(map[string][32]byte, len(accounts))
+	for user, password := range accounts {
+		digests[user] = sha256.Sum256([]byte(password))
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(c *Context) {
+		user, password, ok := c.Req.BasicAuth()
+		if ok {
+			expected, known := digests[user]
+			given := sha256.Sum256([]byte(password))
+			if subtle.ConstantTimeCompare(expected[:], given[:]) == 1 && known {
+				c.Set(UserKey, user)
+				c.Next()
+				return
+			}
+		}
+		c.SetHeader("WWW-Authenticate", challenge)
+		c.AbortWithError(http.StatusUnauthorized, errors.New("gee: invalid basic auth credentials"))
+	}
+}
+
+//APIKey looks the X-API-Key header up, lookup returns the principal of a valid key
+func APIKey(lookup func(key string) (principal string, ok bool)) HandlerFunc {
+	return APIKeyWithHeader("X-API-Key", lookup)
+}
+
+//APIKeyWithHeader is APIKey reading the key from header
+func APIKeyWithHeader(header string, lookup func(key string) (principal string, ok bool)) HandlerFunc {
+	return func(c *Context) {
+		if key := c.Req.Header.Get(header); key != "" {
+			if principal, ok := lookup(key); ok {
+				c.Set(UserKey, principal)
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithError(http.StatusUnauthorized, errors.New("gee: invalid API key"))
+	}
+}
+
+//Claims is the payload of a verified JWT
+type Claims map[string]interface{}
+
+//String returns a string claim, "" when it is missing or not a string
+func (claims Claims) String(name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+//Audience returns aud, which may be a string or a list of strings
+func (claims Claims) Audience() []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		list := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+type JWTConfig struct {
+	//Secret verifies HS256 tokens, nil refuses them
+	Secret []byte
+	//PublicKey verifies RS256 tokens, nil refuses them
+	PublicKey *rsa.PublicKey
+	//Audience must be in aud when set
+	Audience string
+	//Issuer must equal iss when set
+	Issuer string
+	//Leeway tolerates clock skew on exp and nbf
+	Leeway time.Duration
+	//UserClaim is stored as the principal, "sub" by default
+	UserClaim string
+	//Token extracts the token, the Bearer token of Authorization by default
+	Token func(c *Context) string
+}
+
+//JWT verifies a bearer token and stores its claims and principal
+//
+//	api.Use(gee.JWT(gee.JWTConfig{Secret: secret, Audience: "api"}))
+//	claims := c.MustGet(gee.ClaimsKey).(gee.Claims)
+func JWT(conf JWTConfig) HandlerFunc {
+	if conf.Secret == nil && conf.PublicKey == nil {
+		panic("gee: JWT needs a Secret or a PublicKey")
+	}
+	userClaim := conf.UserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	token := conf.Token
+	if token == nil {
+		token = bearerToken
+	}
+
+	return func(c *Context) {
+		claims, err := ParseJWT(token(c), conf)
+		if err != nil {
+			c.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+		c.Set(ClaimsKey, claims)
+		c.Set(UserKey, claims.String(userClaim))
+		c.Next()
+	}
+}
+
+func bearerToken(c *Context) string {
+	auth := c.Req.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+var jwtEncoding = base64.RawURLEncoding
+
+//ParseJWT verifies the signature of token and its exp, nbf, aud and iss
+//claims, only the algorithms with a key in conf are accepted
+func ParseJWT(token string, conf JWTConfig) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("gee: malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("gee: malformed JWT signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && conf.Secret != nil:
+		mac := hmac.New(sha256.New, conf.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("gee: invalid JWT signature")
+		}
+	case header.Alg == "RS256" && conf.PublicKey != nil:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(conf.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("gee: invalid JWT signature")
+		}
+	default:
+		return nil, fmt.Errorf("gee: JWT algorithm %q not accepted", header.Alg)
+	}
+
+	var claims Claims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := claims.validate(conf, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := jwtEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("gee: malformed JWT")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("gee: malformed JWT")
+	}
+	return nil
+}
+
+//maxJWTTime is the end of year 9999 in Unix seconds
+const maxJWTTime = 253402300799
+
+func (claims Claims) validate(conf JWTConfig, now time.Time) error {
+	timeClaim := func(name string) (time.Time, bool, error) {
+		v, ok := claims[name]
+		if !ok {
+			return time.Time{}, false, nil
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return time.Time{}, false, fmt.Errorf("gee: JWT claim %s is not a number", name)
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return time.Time{}, false, fmt.Errorf("gee: JWT claim %s is not a number", name)
+		}
+		//clamp to the years 0-9999 so that int64 can't wrap, a far future
+		//nbf then stays in the future
+		n = math.Max(math.Min(n, maxJWTTime), -maxJWTTime)
+		//whole seconds first, n in nanoseconds overflows int64 after 2262
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), true, nil
+	}
+
+	exp, ok, err := timeClaim("exp")
+	if err != nil {
+		return err
+	}
+	if ok && now.After(exp.Add(conf.Leeway)) {
+		return errors.New("gee: JWT expired")
+	}
+	nbf, ok, err := timeClaim("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Before(nbf.Add(-conf.Leeway)) {
+		return errors.New("gee: JWT not valid yet")
+	}
+
+	if conf.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience() {
+			found = found || aud == conf.Audience
+		}
+		if !found {
+			return errors.New("gee: JWT audience mismatch")
+		}
+	}
+	if conf.Issuer != "" && claims.String("iss") != conf.Issuer {
+		return errors.New("gee: JWT issuer mismatch")
+	}
+	return nil
+}
+
+//SignHS256 issues a token that JWT verifies with the same secret
+func SignHS256(claims Claims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + jwtEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + jwtEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,112 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicAuthAndAPIKey(t *testing.T) {
+	r := New()
+	whoami := func(c *Context) { c.String(http.StatusOK, c.GetString(UserKey)) }
+	admin := r.Group("/admin")
+	admin.Use(BasicAuth(Accounts{"alice": "wonderland"}))
+	admin.GET("/me", whoami)
+	api := r.Group("/api")
+	api.Use(APIKey(func(key string) (string, bool) { return "svc-billing", key == "k-123" }))
+	api.GET("/me", whoami)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/me", nil)
+	req.SetBasicAuth("alice", "wonderland")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	req.SetBasicAuth("alice", "wrong")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic realm=") {
+		t.Fatalf("expected 401 with a challenge, got %d %v", w.Code, w.Header())
+	}
+
+	if w = doRequest(r, "GET", "/api/me", map[string]string{"X-API-Key": "k-123"}); w.Body.String() != "svc-billing" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if w = doRequest(r, "GET", "/api/me", map[string]string{"X-API-Key": "nope"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, payload string) string {
+	signed := jwtEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + jwtEncoding.EncodeToString([]byte(payload))
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + jwtEncoding.EncodeToString(sig)
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("s3cr3t")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	api := r.Group("/api")
+	api.Use(JWT(JWTConfig{Secret: secret, PublicKey: &key.PublicKey, Audience: "api", Leeway: time.Second}))
+	api.GET("/me", func(c *Context) {
+		claims := c.MustGet(ClaimsKey).(Claims)
+		c.String(http.StatusOK, "%s %s", c.GetString(UserKey), claims.String("role"))
+	})
+
+	now := time.Now().Unix()
+	valid, _ := SignHS256(Claims{"sub": "alice", "role": "admin", "aud": []string{"web", "api"}, "exp": now + 60}, secret)
+	w := doRequest(r, "GET", "/api/me", map[string]string{"Authorization": "Bearer " + valid})
+	if w.Code != http.StatusOK || w.Body.String() != "alice admin" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	rs := signRS256(t, key, `{"sub":"bob","aud":"api","nbf":`+strconv.FormatInt(now-5, 10)+`}`)
+	if w = doRequest(r, "GET", "/api/me", map[string]string{"Authorization": "bearer " + rs}); w.Body.String() != "bob " {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+
+	//exp after 2262 doesn't fit in nanoseconds
+	longLived, _ := SignHS256(Claims{"sub": "carol", "aud": "api", "exp": 9999999999.5}, secret)
+	if w = doRequest(r, "GET", "/api/me", map[string]string{"Authorization": "Bearer " + longLived}); w.Body.String() != "carol " {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+
+	//out of range times are clamped, never wrapped around
+	huge, _ := SignHS256(Claims{"sub": "dan", "aud": "api", "exp": 1e19, "nbf": -1e19}, secret)
+	if w = doRequest(r, "GET", "/api/me", map[string]string{"Authorization": "Bearer " + huge}); w.Body.String() != "dan " {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	farNbf, _ := SignHS256(Claims{"sub": "alice", "aud": "api", "nbf": 1e19}, secret)
+	pastExp, _ := SignHS256(Claims{"sub": "alice", "aud": "api", "exp": -1e19}, secret)
+
+	expired, _ := SignHS256(Claims{"sub": "alice", "aud": "api", "exp": now - 60}, secret)
+	early, _ := SignHS256(Claims{"sub": "alice", "aud": "api", "nbf": now + 60}, secret)
+	audience, _ := SignHS256(Claims{"sub": "alice", "aud": "web"}, secret)
+	forged, _ := SignHS256(Claims{"sub": "alice", "aud": "api"}, []byte("other"))
+	none := jwtEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + jwtEncoding.EncodeToString([]byte(`{"sub":"alice","aud":"api"}`)) + "."
+	for name, token := range map[string]string{
+		"missing": "", "expired": expired, "nbf": early, "aud": audience,
+		"signature": forged, "none": none, "garbage": "a.b.c",
+		"far nbf": farNbf, "far past exp": pastExp,
+	} {
+		w = doRequest(r, "GET", "/api/me", map[string]string{"Authorization": "Bearer " + token})
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: expected 401, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package gee
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//defaultMultipartMemory is the default of Engine.MaxMultipartMemory
+const defaultMultipartMemory = 32 << 20 // 32 MB
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+//Bind is ShouldBind that also answers 400 through AbortWithBindError on failure
+func (c *Context) Bind(obj interface{}) error {
+	err := c.ShouldBind(obj)
+	if err != nil {
+		c.AbortWithBindError(err)
+	}
+	return err
+}
+
+//AbortWithBindError renders err from ShouldBind as a 400 JSON body,
+//validation failures list every field:
+//	{"error": "validation failed", "fields": [{"field": "name", "rule": "required", ...}]}
+//A body over BodyLimit goes through the error handler as a 413 instead,
+//a broken validate tag (*TagError) as a 500.
+func (c *Context) AbortWithBindError(err error) {
+	if err == ErrBodyTooLarge {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+	var tagErr *TagError
+	if errors.As(err, &tagErr) {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if errs, ok := err.(ValidationErrors); ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, H{"error": "validation failed", "fields": errs})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, H{"error": err.Error()})
+}
+
+//ShouldBind fills obj, a pointer to a struct, from the request.
+//The body is decoded according to Content-Type: JSON and XML use their own
+//struct tags, form-urlencoded and multipart fill fields tagged form:"name".
+//Then fields tagged query:"name", path:"name" and header:"Name" are filled
+//from the URL query, the route params and the request headers.
+//Values are converted to the field type, slices take every value of a key
+//and time.Time honours a time_format:"2006-01-02" tag, RFC3339 by default.
+//Last the validate tags are checked, see Validate.
+func (c *Context) ShouldBind(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("gee: ShouldBind expects a non-nil pointer to a struct")
+	}
+	if err := c.bindBody(obj); err != nil {
+		return err
+	}
+
+	query := c.Req.URL.Query()
+	sources := []struct {
+		tag    string
+		lookup func(key string) ([]string, bool)
+	}{
+		{"query", func(key string) ([]string, bool) {
+			vals, ok := query[key]
+			return vals, ok
+		}},
+		{"path", func(key string) ([]string, bool) {
+			for _, p := range c.Params {
+				if p.Key == key {
+					return []string{p.Value}, true
+				}
+			}
+			return nil, false
+		}},
+		{"header", func(key string) ([]string, bool) {
+			vals, ok := c.Req.Header[textproto.CanonicalMIMEHeaderKey(key)]
+			return vals, ok
+		}},
+	}
+	for _, source := range sources {
+		if err := mapValues(v.Elem(), source.tag, source.lookup); err != nil {
+			return err
+		}
+	}
+	return Validate(obj)
+}
+
+func (c *Context) bindBody(obj interface{}) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody || c.Req.ContentLength == 0 {
+		return nil
+	}
+	contentType := c.Req.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("gee: invalid Content-Type %s: %v", contentType, err)
+	}
+
+	switch mediaType {
+	case "application/json":
+		err = json.NewDecoder(c.Req.Body).Decode(obj)
+	case "application/xml", "text/xml":
+		err = xml.NewDecoder(c.Req.Body).Decode(obj)
+	case "application/x-www-form-urlencoded":
+		if err = c.parseForm(); err == nil {
+			err = mapForm(obj, c.Req.PostForm)
+		}
+	case "multipart/form-data":
+		var form *multipart.Form
+		if form, err = c.MultipartForm(); err == nil {
+			err = mapForm(obj, form.Value)
+		}
+	default:
+		return fmt.Errorf("gee: unsupported Content-Type %s", mediaType)
+	}
+	if err = c.bodyError(err); err == ErrBodyTooLarge {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("gee: binding %s body: %v", mediaType, err)
+	}
+	return nil
+}
+
+func mapForm(obj interface{}, form map[string][]string) error {
+	return mapValues(reflect.ValueOf(obj).Elem(), "form", func(key string) ([]string, bool) {
+		vals, ok := form[key]
+		return vals, ok
+	})
+}
+
+//mapValues sets every field of the struct v tagged with tag,
+//untagged struct fields are walked into
+func mapValues(v reflect.Value, tag string, lookup func(key string) ([]string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue //unexported
+		}
+		fv := v.Field(i)
+
+		name, ok := field.Tag.Lookup(tag)
+		if !ok {
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := mapValues(fv, tag, lookup); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		vals, ok := lookup(name)
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setField(fv, field, vals); err != nil {
+			return fmt.Errorf("gee: binding %s %s to field %s: %v", tag, name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, field reflect.StructField, vals []string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setField(elem.Elem(), field, vals); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setValue(slice.Index(i), field, s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, field, vals[0])
+}
+
+func setValue(v reflect.Value, field reflect.StructField, s string) error {
+	switch v.Type() {
+	case timeType:
+		return setTime(v, field, s)
+	case durationType:
+		if s == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(s))
+			return nil
+		}
+	}
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+//setTime parses s with the time_format tag, "unix" means seconds since epoch
+func setTime(v reflect.Value, field reflect.StructField, s string) error {
+	if s == "" {
+		v.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	layout := field.Tag.Get("time_format")
+	if layout == "unix" {
+		sec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(time.Unix(sec, 0)))
+		return nil
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -0,0 +1,191 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindPage struct {
+	Page int      `query:"page"`
+	Tags []string `query:"tag"`
+}
+
+type bindUser struct {
+	bindPage
+	ID      uint64        `path:"id"`
+	Name    string        `json:"name" xml:"name" form:"name"`
+	Age     *int          `json:"age" xml:"age" form:"age"`
+	Admin   bool          `form:"admin"`
+	Born    time.Time     `form:"born" time_format:"2006-01-02"`
+	Timeout time.Duration `header:"X-Timeout"`
+	TraceID string        `header:"x-trace-id"`
+	Ignored string        `query:"-"`
+}
+
+func TestBind(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("name", "geek")
+	_ = mw.WriteField("age", "20")
+	_ = mw.WriteField("admin", "true")
+	_ = mw.WriteField("born", "2000-01-02")
+	_ = mw.Close()
+
+	age := 20
+	born := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	bodies := map[string]string{
+		"application/json":                  `{"name":"geek","age":20}`,
+		"application/xml; charset=utf-8":    `<bindUser><name>geek</name><age>20</age></bindUser>`,
+		"application/x-www-form-urlencoded": "name=geek&age=20&admin=1&born=2000-01-02",
+		mw.FormDataContentType():            body.String(),
+	}
+	for contentType, payload := range bodies {
+		var got bindUser
+		r := New()
+		r.POST("/users/:id", func(c *Context) {
+			if err := c.Bind(&got); err != nil {
+				t.Fatalf("%s: %v", contentType, err)
+			}
+		})
+		req := httptest.NewRequest("POST", "/users/42?page=3&tag=a&tag=b&Ignored=x", strings.NewReader(payload))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Timeout", "1.5s")
+		req.Header.Set("X-Trace-Id", "abc")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		expect := bindUser{
+			bindPage: bindPage{Page: 3, Tags: []string{"a", "b"}},
+			ID:       42, Name: "geek", Age: &age,
+			Timeout: 1500 * time.Millisecond, TraceID: "abc",
+		}
+		if !strings.HasPrefix(contentType, "application/json") && !strings.HasPrefix(contentType, "application/xml") {
+			expect.Admin, expect.Born = true, born
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("%s: got %+v, expect %+v", contentType, got, expect)
+		}
+	}
+}
+
+func TestBindError(t *testing.T) {
+	cases := []struct {
+		contentType, body, query string
+	}{
+		{"application/json", `{"name":`, ""},
+		{"text/csv", "a,b", ""},
+		{"", "", "page=abc"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/?"+c.query, strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		ctx := &Context{Req: req}
+		if err := ctx.ShouldBind(&bindUser{}); err == nil {
+			t.Fatalf("%s %q should fail", c.contentType, c.body)
+		}
+	}
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	if err := ctx.ShouldBind(bindUser{}); err == nil {
+		t.Fatal("non-pointer should fail")
+	}
+}
+
+type signup struct {
+	Name    string   `json:"name" validate:"required,min=2,max=8"`
+	Email   string   `json:"email" validate:"required,email"`
+	Role    string   `json:"role" validate:"omitempty,oneof=admin user"`
+	Age     int      `json:"age" validate:"omitempty,min=18"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func TestValidate(t *testing.T) {
+	ok := signup{Name: "geek", Email: "geek@example.com", Role: "user", Age: 20}
+	ok.Address.City = "Beijing"
+	if err := Validate(&ok); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := signup{Name: "g", Email: "geek", Role: "root", Age: 3, Tags: []string{"a", "b", "c"}}
+	err := Validate(bad)
+	errs, isValidation := err.(ValidationErrors)
+	if !isValidation {
+		t.Fatalf("expect ValidationErrors, got %v", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	expect := []string{"name:min", "email:email", "role:oneof", "age:min", "tags:max", "address.city:required"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %v, expect %v", got, expect)
+	}
+
+	//zero values get every rule unless omitempty skips them
+	err = Validate(struct {
+		Age  int     `validate:"min=18"`
+		Name string  `validate:"min=3"`
+		Nick string  `validate:"omitempty,min=3"`
+		Bio  *string `validate:"max=140"`
+	}{})
+	if errs, _ := err.(ValidationErrors); len(errs) != 2 || errs[0].Field != "Age" || errs[1].Field != "Name" {
+		t.Fatalf("expect Age and Name to fail, got %v", err)
+	}
+
+	if _, ok := Validate(struct {
+		N int `validate:"between=1"`
+	}{1}).(*TagError); !ok {
+		t.Fatal("unknown rule should give a TagError")
+	}
+}
+
+func TestBindValidationResponse(t *testing.T) {
+	r := New()
+	r.POST("/signup", func(c *Context) {
+		var s signup
+		if c.Bind(&s) != nil {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"name":"geek"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Error  string
+		Fields []FieldError
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 JSON, got %d %s", w.Code, w.Body.String())
+	}
+	if body.Error != "validation failed" || len(body.Fields) != 2 || body.Fields[0].Field != "email" {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+}
+
+func TestBindTagErrorResponse(t *testing.T) {
+	r := New()
+	r.GET("/search", func(c *Context) {
+		var q struct {
+			Limit int `query:"limit" validate:"min=abc"`
+		}
+		c.Bind(&q)
+	})
+	//a broken tag is the server's fault and its details stay internal
+	w := doRequest(r, "GET", "/search?limit=5")
+	if w.Code != http.StatusInternalServerError || strings.TrimSpace(w.Body.String()) != `{"code":500,"error":"Internal Server Error"}` {
+		t.Fatalf("expect 500 from the error handler, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,302 @@
+package gee
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type CompressConfig struct {
+	//Level is the gzip and deflate level, gzip.DefaultCompression by default
+	Level int
+	//MinLength is the smallest body that gets compressed, 1024 bytes by default
+	MinLength int
+	//ContentTypes lists the media types worth compressing, "text/*" matches
+	//every text type. Common text, JSON, XML and JavaScript types by default.
+	ContentTypes []string
+}
+
+var defaultCompressTypes = []string{
+	"text/html", "text/plain", "text/css", "text/javascript", "text/xml", "text/csv",
+	MIMEJSON, MIMEJavaScript, MIMEXML, MIMEYAML, "application/problem+json", "image/svg+xml",
+}
+
+//encoder is what gzip.Writer and zlib.Writer have in common
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type compressor struct {
+	minLength int
+	types     map[string]bool
+	prefixes  []string
+	gzipPool  sync.Pool
+	zlibPool  sync.Pool
+	writers   sync.Pool
+}
+
+//Compress gzips or deflates responses according to Accept-Encoding
+func Compress() HandlerFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+//CompressWithConfig panics on an invalid Level. Responses that already have
+//a Content-Encoding, partial content and bodies without an allowed type are
+//sent as they are.
+func CompressWithConfig(conf CompressConfig) HandlerFunc {
+	level := conf.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		panic("gee: " + err.Error())
+	}
+	cp := &compressor{minLength: conf.MinLength, types: make(map[string]bool)}
+	if cp.minLength <= 0 {
+		cp.minLength = 1024
+	}
+	contentTypes := conf.ContentTypes
+	if contentTypes == nil {
+		contentTypes = defaultCompressTypes
+	}
+	for _, t := range contentTypes {
+		if strings.HasSuffix(t, "/*") {
+			cp.prefixes = append(cp.prefixes, t[:len(t)-1])
+		} else {
+			cp.types[t] = true
+		}
+	}
+	cp.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+		return w
+	}
+	cp.zlibPool.New = func() interface{} {
+		w, _ := zlib.NewWriterLevel(ioutil.Discard, level)
+		return w
+	}
+	cp.writers.New = func() interface{} {
+		return &compressWriter{cp: cp}
+	}
+
+	return func(c *Context) {
+		w := cp.writers.Get().(*compressWriter)
+		w.reset(c.Writer, acceptedEncoding(c.Req.Header.Get("Accept-Encoding")))
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if err := recover(); err != nil {
+				//Recovery answers with the original writer, nothing buffered is sent
+				w.release()
+				panic(err)
+			}
+			w.finish()
+			w.release()
+		}()
+		c.Next()
+	}
+}
+
+func (cp *compressor) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if cp.types[mediaType] {
+		return true
+	}
+	for _, prefix := range cp.prefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//acceptedEncoding picks gzip or deflate by their q-values, gzip wins ties
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	wildcard := -1.0
+	seen := map[string]bool{}
+	for _, item := range strings.Split(header, ",") {
+		name, params := item, ""
+		if i := strings.IndexByte(item, ';'); i >= 0 {
+			name, params = item[:i], item[i+1:]
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(params[2:], 64); err == nil {
+				q = v
+			}
+		}
+		switch name {
+		case "gzip", "x-gzip":
+			name = "gzip"
+		case "deflate":
+		case "*":
+			wildcard = q
+			continue
+		default:
+			continue
+		}
+		seen[name] = true
+		if q > 0 && (q > bestQ || (q == bestQ && name == "gzip")) {
+			best, bestQ = name, q
+		}
+	}
+	//the wildcard stands for the codings not listed explicitly
+	if best == "" && wildcard > 0 {
+		if !seen["gzip"] {
+			return "gzip"
+		}
+		if !seen["deflate"] {
+			return "deflate"
+		}
+	}
+	return best
+}
+
+//compressWriter holds the body back until MinLength bytes are known to
+//come, then picks between compressing and passing it through
+type compressWriter struct {
+	ResponseWriter
+	cp       *compressor
+	encoding string //negotiated, "" when the client takes none
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (w *compressWriter) reset(rw ResponseWriter, encoding string) {
+	w.ResponseWriter = rw
+	w.encoding = encoding
+	w.buf = w.buf[:0]
+	w.decided = false
+	w.enc = nil
+}
+
+func (w *compressWriter) release() {
+	if w.enc != nil {
+		w.enc.Reset(ioutil.Discard)
+		if w.encoding == "gzip" {
+			w.cp.gzipPool.Put(w.enc)
+		} else {
+			w.cp.zlibPool.Put(w.enc)
+		}
+	}
+	w.ResponseWriter = nil
+	w.enc = nil
+	if cap(w.buf) <= 64<<10 {
+		w.cp.writers.Put(w)
+	}
+}
+
+//Written is true as soon as a body byte was buffered, so that the error
+//handler doesn't add a second body
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.decided || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.cp.minLength && !w.lengthKnown() {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+//lengthKnown reports a Content-Length large enough to decide right away
+func (w *compressWriter) lengthKnown() bool {
+	n, err := strconv.Atoi(w.Header().Get("Content-Length"))
+	return err == nil && n >= w.cp.minLength
+}
+
+//decide sends the headers and the buffered bytes, compressed or not
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	status := w.Status()
+	compress := w.cp.allowed(header.Get("Content-Type")) &&
+		status != http.StatusPartialContent && status != http.StatusNoContent &&
+		status != http.StatusNotModified && status >= http.StatusOK
+	if compress && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" {
+		header.Add("Vary", "Accept-Encoding")
+		compress = w.encoding != "" && len(w.buf) >= w.cp.minLength
+	} else {
+		compress = false
+	}
+
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.enc = w.cp.gzipPool.Get().(encoder)
+		} else {
+			w.enc = w.cp.zlibPool.Get().(encoder)
+		}
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide()
+	}
+}
+
+//Flush sends what is buffered, streaming responses are compressed as they go
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			//nothing was written, e.g. a hijacked connection or an empty body
+			return
+		}
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	for header, expect := range map[string]string{
+		"":                          "",
+		"gzip, deflate, br":         "gzip",
+		"deflate":                   "deflate",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, *":               "deflate",
+		"gzip;q=0, deflate;q=0, *":  "",
+		"*":                         "gzip",
+		"identity":                  "",
+		"br;q=1.0, gzip;q=0.8":      "gzip",
+		"deflate;q=0.5, gzip;q=0.5": "gzip",
+	} {
+		if got := acceptedEncoding(header); got != expect {
+			t.Errorf("%q: expected %q, got %q", header, expect, got)
+		}
+	}
+}
+
+func newCompressEngine() *Engine {
+	r := New()
+	r.Use(Compress())
+	big := strings.Repeat("gee compresses this line\n", 200)
+	r.GET("/big", func(c *Context) { c.String(http.StatusOK, big) })
+	r.GET("/small", func(c *Context) { c.String(http.StatusOK, "tiny") })
+	r.GET("/png", func(c *Context) { c.Render(http.StatusOK, Data{Type: "image/png", Data: []byte(big)}) })
+	r.GET("/encoded", func(c *Context) {
+		c.SetHeader("Content-Encoding", "br")
+		c.String(http.StatusOK, big)
+	})
+	r.GET("/fail", func(c *Context) { c.Fail(http.StatusTeapot, strings.Repeat("x", 2000)) })
+	return r
+}
+
+func TestCompress(t *testing.T) {
+	r := newCompressEngine()
+	big := strings.Repeat("gee compresses this line\n", 200)
+
+	w := doRequest(r, "GET", "/big", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" || w.Body.Len() >= len(big) {
+		t.Fatalf("expected a gzip body, got %v %d bytes", w.Header(), w.Body.Len())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(zr); string(body) != big {
+		t.Fatalf("gzip body doesn't round trip")
+	}
+
+	w = doRequest(r, "GET", "/big", map[string]string{"Accept-Encoding": "deflate"})
+	fr, err := zlib.NewReader(w.Body)
+	if err != nil || w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected a deflate body, got %v %v", w.Header(), err)
+	}
+	if body, _ := ioutil.ReadAll(fr); string(body) != big {
+		t.Fatalf("deflate body doesn't round trip")
+	}
+
+	w = doRequest(r, "GET", "/fail", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != http.StatusTeapot || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("error responses go through the writer too, got %d %v", w.Code, w.Header())
+	}
+
+	for path, vary := range map[string]string{"/small": "Accept-Encoding", "/png": "", "/encoded": ""} {
+		w = doRequest(r, "GET", path, map[string]string{"Accept-Encoding": "gzip"})
+		if w.Header().Get("Content-Encoding") == "gzip" || w.Header().Get("Vary") != vary {
+			t.Fatalf("%s shouldn't be compressed, got %v", path, w.Header())
+		}
+	}
+	if w = doRequest(r, "GET", "/big"); w.Body.String() != big || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("clients without Accept-Encoding get the plain body, got %v", w.Header())
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	r := newCompressEngine()
+	req := httptest.NewRequest(http.MethodGet, "/big", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+}
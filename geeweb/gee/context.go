@@ -1,37 +1,90 @@
 package gee
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+//abortIndex is past the end of any chain, Next stops once index reaches it
+const abortIndex = math.MaxInt16
+
 type H map[string]interface{}
 
+//Param is a single URL parameter captured by the router
+type Param struct {
+	Key string
+	Value string
+}
+
+//Params is kept on the Context and reused across requests
+type Params []Param
+
+//ByName returns the value of the first param named name, or ""
+func (ps Params) ByName(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+//Context is pooled: once the handlers return it is reset and handed to the
+//next request, so don't keep it, or give it to a goroutine or a call that
+//outlives the request. Pass c.Copy() there instead.
 type Context struct {
-	Writer http.ResponseWriter
+	writermem responseWriter
+	Writer ResponseWriter
 	Req *http.Request
 
 	Path string
 	Method string
-	Params map[string]string
-	StatusCode int
+	Params Params
 
 	//middleware
 	handlers []HandlerFunc
 	index int
 
+	//request scoped values shared between middlewares and handlers
+	mu sync.RWMutex
+	Keys map[string]interface{}
+
+	//collected by Error, rendered by the engine error handler
+	Errors []error
+	errorsHandled bool
+
 	engine *Engine
 }
 
-func newContext(w http.ResponseWriter, r *http.Request)*Context{
-	return &Context{
-		Writer: w,
-		Req: r,
-		Path: r.URL.Path,
-		Method: r.Method,
-		index: -1,
-	}
+//reset prepares a pooled Context for a new request, slices keep their capacity
+func (c *Context)reset(w http.ResponseWriter, r *http.Request){
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = r
+	c.Path = r.URL.Path
+	c.Method = r.Method
+	c.Params = c.Params[:0]
+	c.handlers = nil
+	c.index = -1
+	c.Keys = nil
+	c.Errors = c.Errors[:0]
+	c.errorsHandled = false
 }
 
 func (c *Context)Next(){
@@ -40,59 +93,403 @@ func (c *Context)Next(){
 	for ; c.index < s; c.index++ {
 		c.handlers[c.index](c)
 	}
+	//the innermost Next renders errors, so outer middlewares see the response
+	c.handleErrors()
+}
+
+//Abort stops the remaining handlers from running, the current one still returns normally
+func (c *Context)Abort(){
+	c.index = abortIndex
+}
+
+func (c *Context)IsAborted() bool{
+	return c.index >= abortIndex
+}
+
+func (c *Context)AbortWithStatus(code int){
+	c.Abort()
+	c.Status(code)
+}
+
+func (c *Context)AbortWithStatusJSON(code int, obj interface{}){
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+func (c *Context)Set(key string, value interface{}){
+	c.mu.Lock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+	c.mu.Unlock()
+}
+
+func (c *Context)Get(key string)(value interface{}, exists bool){
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+//MustGet panics if key was never set
+func (c *Context)MustGet(key string) interface{}{
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+func (c *Context)GetString(key string)(s string){
+	if value, ok := c.Get(key); ok {
+		s, _ = value.(string)
+	}
+	return
+}
+
+func (c *Context)GetBool(key string)(b bool){
+	if value, ok := c.Get(key); ok {
+		b, _ = value.(bool)
+	}
+	return
+}
+
+func (c *Context)GetInt(key string)(i int){
+	if value, ok := c.Get(key); ok {
+		i, _ = value.(int)
+	}
+	return
+}
+
+func (c *Context)GetInt64(key string)(i int64){
+	if value, ok := c.Get(key); ok {
+		i, _ = value.(int64)
+	}
+	return
+}
+
+func (c *Context)GetFloat64(key string)(f float64){
+	if value, ok := c.Get(key); ok {
+		f, _ = value.(float64)
+	}
+	return
+}
+
+func (c *Context)GetTime(key string)(t time.Time){
+	if value, ok := c.Get(key); ok {
+		t, _ = value.(time.Time)
+	}
+	return
+}
+
+func (c *Context)GetDuration(key string)(d time.Duration){
+	if value, ok := c.Get(key); ok {
+		d, _ = value.(time.Duration)
+	}
+	return
+}
+
+func (c *Context)GetStringSlice(key string)(ss []string){
+	if value, ok := c.Get(key); ok {
+		ss, _ = value.([]string)
+	}
+	return
+}
+
+//Context implements context.Context on top of Req.Context(),
+//so it can be handed to anything that honours deadlines and cancellation
+//while the request runs, see Copy for anything longer.
+
+func (c *Context)Deadline()(deadline time.Time, ok bool){
+	return c.Req.Context().Deadline()
+}
+
+func (c *Context)Done() <-chan struct{}{
+	return c.Req.Context().Done()
+}
+
+func (c *Context)Err() error{
+	return c.Req.Context().Err()
+}
+
+//Value looks at the values set with Set first, then at the request context
+func (c *Context)Value(key interface{}) interface{}{
+	if k, ok := key.(string); ok {
+		if value, exists := c.Get(k); exists {
+			return value
+		}
+	}
+	return c.Req.Context().Value(key)
+}
+
+//Copy returns a Context that stays valid after the request, with the same
+//Req, Params and Keys. It can't write the response and runs no handlers.
+func (c *Context)Copy()*Context{
+	cp := &Context{
+		writermem: c.writermem,
+		Req: c.Req,
+		Path: c.Path,
+		Method: c.Method,
+		Params: append(Params(nil), c.Params...),
+		index: abortIndex,
+		engine: c.engine,
+	}
+	cp.writermem.ResponseWriter = nil
+	cp.Writer = &cp.writermem
+
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
 }
 
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
 }
 
+//PostForm is the first value of key in a urlencoded or multipart body,
+//the query string isn't looked at. A body that can't be parsed gives "".
 func (c *Context)PostForm(key string) string {
-	return c.Req.FormValue(key)
+	if err := c.parseForm(); err != nil {
+		return ""
+	}
+	return c.Req.PostForm.Get(key)
+}
+
+//ClientIP is the remote address of the request, or the first address of
+//X-Forwarded-For / X-Real-Ip when Engine.ForwardedByClientIP is set
+func (c *Context)ClientIP() string{
+	if c.engine != nil && c.engine.ForwardedByClientIP {
+		if forwarded := c.Req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			if ip := strings.TrimSpace(strings.Split(forwarded, ",")[0]); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-Ip")); ip != "" {
+			return ip
+		}
+	}
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return ip
 }
 
 func (c *Context)Query(key string)string{
 	return c.Req.URL.Query().Get(key)
 }
 
+//Status sets the response code, it is sent with the first body byte
 func (c *Context)Status(code int){
-	c.StatusCode = code
 	c.Writer.WriteHeader(code)
 }
 
+//Redirect answers with a 3xx code and Location, or 201 Created
+func (c *Context)Redirect(code int, location string){
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("gee: cannot redirect with status code %d", code))
+	}
+	http.Redirect(c.Writer, c.Req, location, code)
+}
+
 func (c *Context)SetHeader(key, value string){
 	c.Writer.Header().Set(key, value)
 }
 
-func (c *Context)String(code int, format string, values...interface{}){
-	c.SetHeader("Content-Type", "text/plain")
+//maxPooledBuffer keeps a single large response from pinning its buffer in bufferPool
+const maxPooledBuffer = 64 << 10
+
+//Render encodes the body into a buffer first, so an encoding error can
+//still be answered with a 500 instead of a half written response.
+//Data has nothing to encode and is written as is.
+func (c *Context)Render(code int, r Render){
+	if data, ok := r.(Data); ok {
+		c.writeHeader(code, data.ContentType())
+		c.Writer.Write(data.Data)
+		return
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func(){
+		if buf.Cap() <= maxPooledBuffer {
+			bufferPool.Put(buf)
+		}
+	}()
+
+	if err := r.Render(buf); err != nil {
+		c.Fail(http.StatusInternalServerError, err)
+		return
+	}
+	c.writeHeader(code, r.ContentType())
+	c.Writer.Write(buf.Bytes())
+}
+
+func (c *Context)writeHeader(code int, contentType string){
+	if contentType != "" {
+		c.SetHeader("Content-Type", contentType)
+	}
 	c.Status(code)
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+}
+
+func (c *Context)String(code int, format string, values...interface{}){
+	c.Render(code, String{Format: format, Data: values})
 }
 
 func (c *Context)JSON(code int, obj interface{}){
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
+	c.Render(code, JSON{Data: obj})
+}
+
+func (c *Context)IndentedJSON(code int, obj interface{}){
+	c.Render(code, IndentedJSON{Data: obj})
+}
+
+//SecureJSON prefixes arrays with while(1);
+func (c *Context)SecureJSON(code int, obj interface{}){
+	c.Render(code, SecureJSON{Prefix: "while(1);", Data: obj})
+}
+
+//JSONP uses the callback query param, plain JSON is written without it
+func (c *Context)JSONP(code int, obj interface{}){
+	callback := c.Query("callback")
+	if callback == "" {
+		c.JSON(code, obj)
+		return
 	}
+	c.Render(code, JSONP{Callback: callback, Data: obj})
 }
 
-func (c *Context) Data(code int, data []byte){
-	c.Status(code)
-	c.Writer.Write(data)
+func (c *Context)XML(code int, obj interface{}){
+	c.Render(code, XML{Data: obj})
+}
+
+func (c *Context)YAML(code int, obj interface{}){
+	c.Render(code, YAML{Data: obj})
+}
+
+func (c *Context)MsgPack(code int, obj interface{}){
+	c.Render(code, MsgPack{Data: obj})
 }
 
-func (c *Context) Fail(code int, obj interface{}){
-	fmt.Println("Code:", code, "Error:", obj)
+func (c *Context)ProtoBuf(code int, obj interface{}){
+	c.Render(code, ProtoBuf{Data: obj})
+}
+
+func (c *Context) Data(code int, data []byte){
+	c.Render(code, Data{Data: data})
 }
 
 func (c *Context)HTML(code int, name string, data interface{}){
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	//c.Writer.Write([]byte(html))
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(500, err.Error())
+	tmpl := c.engine.htmlTemplates
+	if state, ok := c.Get(csrfKey); ok {
+		var err error
+		if tmpl, err = state.(*csrfState).templates(c.engine); err != nil {
+			c.Fail(http.StatusInternalServerError, err)
+			return
+		}
 	}
-}
\ No newline at end of file
+	c.Render(code, HTML{Template: tmpl, Name: name, Data: data})
+}
+
+//Negotiate renders obj in the format the Accept header prefers among offered,
+//json, xml, yaml and msgpack by default, plus protobuf for a proto.Message.
+//406 is answered when none is acceptable.
+func (c *Context)Negotiate(code int, obj interface{}, offered...string){
+	if len(offered) == 0 {
+		offered = []string{MIMEJSON, MIMEXML, MIMEYAML, MIMEMsgPack}
+		if _, ok := obj.(proto.Message); ok {
+			offered = append(offered, MIMEProtoBuf)
+		}
+	}
+
+	switch c.NegotiateFormat(offered...) {
+	case MIMEJSON:
+		c.JSON(code, obj)
+	case MIMEXML, "text/xml":
+		c.XML(code, obj)
+	case MIMEYAML:
+		c.YAML(code, obj)
+	case MIMEMsgPack:
+		c.MsgPack(code, obj)
+	case MIMEProtoBuf:
+		c.ProtoBuf(code, obj)
+	default:
+		c.SetHeader("Content-Type", MIMEPlain)
+		c.Status(http.StatusNotAcceptable)
+		c.Writer.Write([]byte("406 Not Acceptable\n"))
+	}
+}
+
+//NegotiateFormat returns the offered MIME type with the highest q in Accept.
+//An offer takes the q of the most specific range it matches, so q=0
+//refuses it even when */* is accepted. Exact matches beat wildcards on
+//equal q, then the first offer wins, also for a missing Accept header.
+//"" means none is acceptable.
+func (c *Context)NegotiateFormat(offered...string) string{
+	accept := c.Req.Header.Get("Accept")
+	if len(offered) == 0 {
+		return ""
+	}
+	if accept == "" {
+		return offered[0]
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q float64
+		specific int //2 for type/subtype, 1 for type/*, 0 for */*
+	}
+	var ranges []mediaRange
+	for _, item := range strings.Split(accept, ","){
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		specific := 2
+		if mediaType == "*/*" {
+			specific = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specific = 1
+		}
+		ranges = append(ranges, mediaRange{mediaType, q, specific})
+	}
+
+	best, bestQ, bestSpecific := "", 0.0, -1
+	for _, offer := range offered {
+		q, specific := 0.0, -1
+		for _, r := range ranges {
+			if r.specific > specific && matchMediaType(r.mediaType, offer) {
+				q, specific = r.q, r.specific
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specific > bestSpecific) {
+			best, bestQ, bestSpecific = offer, q, specific
+		}
+	}
+	return best
+}
+
+func matchMediaType(accepted string, offer string) bool{
+	if accepted == "*/*" || accepted == offer {
+		return true
+	}
+	if strings.HasSuffix(accepted, "/*") {
+		return strings.HasPrefix(offer, accepted[:len(accepted)-1])
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package gee
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	//AllowOrigins lists exact origins like "https://app.example.com",
+	//wildcards like "https://*.example.com" or "*" for any origin
+	AllowOrigins []string
+	//AllowOriginFunc is asked about origins AllowOrigins doesn't list
+	AllowOriginFunc func(origin string) bool
+	//AllowMethods defaults to GET, POST, PUT, PATCH, DELETE and HEAD
+	AllowMethods []string
+	//AllowHeaders are the request headers a preflight may ask for,
+	//Origin, Accept, Content-Type, Authorization and X-Requested-With by default
+	AllowHeaders []string
+	//ExposeHeaders are the response headers scripts may read
+	ExposeHeaders []string
+	//AllowCredentials lets browsers send cookies, it can't go with origin "*"
+	AllowCredentials bool
+	//MaxAge is how long a preflight answer may be cached, 0 leaves it to the browser
+	MaxAge time.Duration
+}
+
+//CORS answers cross-origin requests, on the engine or on a group. Preflights
+//are answered for every path the group registered, whatever the methods of
+//its routes.
+//
+//	api.Use(gee.CORS(gee.CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+//
+//Cross-origin requests from origins that aren't allowed get a 403 through the
+//engine error handler, same-origin requests are left alone.
+func CORS(conf CORSConfig) HandlerFunc {
+	allowAll := false
+	exact := make(map[string]bool)
+	var wildcards [][2]string
+	for _, origin := range conf.AllowOrigins {
+		switch i := strings.IndexByte(origin, '*'); {
+		case origin == "*":
+			allowAll = true
+		case i >= 0:
+			wildcards = append(wildcards, [2]string{strings.ToLower(origin[:i]), strings.ToLower(origin[i+1:])})
+		default:
+			exact[strings.ToLower(origin)] = true
+		}
+	}
+	if allowAll && conf.AllowCredentials {
+		panic("gee: CORS can't allow credentials for every origin")
+	}
+
+	methods := conf.AllowMethods
+	if methods == nil {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	allowedMethods := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		allowedMethods[strings.ToUpper(method)] = true
+	}
+	headers := conf.AllowHeaders
+	if headers == nil {
+		headers = []string{"Origin", "Accept", "Content-Type", "Authorization", "X-Requested-With"}
+	}
+	allowedHeaders := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(conf.MaxAge / time.Second))
+	}
+
+	originAllowed := func(origin string) bool {
+		lower := strings.ToLower(origin)
+		if allowAll || exact[lower] {
+			return true
+		}
+		for _, w := range wildcards {
+			if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+				return true
+			}
+		}
+		return conf.AllowOriginFunc != nil && conf.AllowOriginFunc(origin)
+	}
+
+	return func(c *Context) {
+		origin := c.Req.Header.Get("Origin")
+		header := c.Writer.Header()
+		if !allowAll {
+			//the answer depends on Origin, caches must not mix them up
+			header.Add("Vary", "Origin")
+		}
+		if origin == "" || isSameOrigin(origin, c.Req.Host) {
+			c.Next()
+			return
+		}
+		if !originAllowed(origin) {
+			c.Fail(http.StatusForbidden, "origin not allowed")
+			return
+		}
+
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		requestMethod := c.Req.Header.Get("Access-Control-Request-Method")
+		if c.Method != http.MethodOptions || requestMethod == "" {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		//preflight, answered here whatever the routes are
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		if !allowedMethods[strings.ToUpper(requestMethod)] {
+			c.Fail(http.StatusForbidden, "method not allowed by CORS")
+			return
+		}
+		for _, h := range strings.Split(c.Req.Header.Get("Access-Control-Request-Headers"), ",") {
+			if h = strings.TrimSpace(h); h != "" && !allowedHeaders[http.CanonicalHeaderKey(h)] {
+				c.Fail(http.StatusForbidden, "header "+h+" not allowed by CORS")
+				return
+			}
+		}
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+//isSameOrigin compares the host of origin with the Host of the request,
+//browsers send Origin on same-origin POSTs too
+func isSameOrigin(origin string, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
@@ -0,0 +1,110 @@
+package gee
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCORSEngine() *Engine {
+	r := New()
+	r.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".localhost:3000") },
+		AllowMethods:     []string{"GET", "POST", "DELETE"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	api := r.Group("/api")
+	api.GET("/users", func(c *Context) { c.String(http.StatusOK, "users") })
+	api.POST("/users", func(c *Context) { c.String(http.StatusCreated, "created") })
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := newCORSEngine()
+	w := doRequest(r, "OPTIONS", "/api/users", map[string]string{
+		"Origin":                         "https://pr-42.preview.example.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	})
+	h := w.Header()
+	if w.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://pr-42.preview.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, POST, DELETE" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Max-Age") != "43200" {
+		t.Fatalf("unexpected preflight answer %d %v", w.Code, h)
+	}
+
+	for name, header := range map[string]map[string]string{
+		"origin": {"Origin": "https://evil.example", "Access-Control-Request-Method": "GET"},
+		"method": {"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"},
+		"header": {"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"},
+	} {
+		if w = doRequest(r, "OPTIONS", "/api/users", header); w.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d", name, w.Code)
+		}
+	}
+
+	//plain OPTIONS keeps the router answer
+	if w = doRequest(r, "OPTIONS", "/api/users"); w.Code != http.StatusNoContent || w.Header().Get("Allow") == "" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	r := newCORSEngine()
+	w := doRequest(r, "GET", "/api/users", map[string]string{"Origin": "http://web.localhost:3000"})
+	h := w.Header()
+	if w.Body.String() != "users" || h.Get("Access-Control-Allow-Origin") != "http://web.localhost:3000" ||
+		h.Get("Access-Control-Expose-Headers") != "X-Total-Count" || h.Get("Vary") != "Origin" {
+		t.Fatalf("got %q %v", w.Body.String(), h)
+	}
+	if w = doRequest(r, "POST", "/api/users", map[string]string{"Origin": "https://evil.example"}); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed origins get 403, got %d", w.Code)
+	}
+	w = doRequest(r, "POST", "http://example.com/api/users", map[string]string{"Origin": "http://example.com"})
+	if w.Code != http.StatusCreated || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("same-origin requests are not CORS, got %d %v", w.Code, w.Header())
+	}
+
+	open := New()
+	open.Use(CORS(CORSConfig{AllowOrigins: []string{"*"}}))
+	open.GET("/", func(c *Context) {})
+	if w = doRequest(open, "GET", "/", map[string]string{"Origin": "https://any.example"}); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected *, got %v", w.Header())
+	}
+}
+
+func TestCORSOnGroup(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "home") })
+	api := r.Group("/api")
+	api.Use(CORS(CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+	api.GET("/users", func(c *Context) { c.String(http.StatusOK, "users") })
+	api.POST("/users", func(c *Context) { c.String(http.StatusCreated, "created") })
+
+	//the preflight has no OPTIONS route, the group middlewares answer it
+	w := doRequest(r, "OPTIONS", "/api/users", map[string]string{
+		"Origin":                        "https://app.example.com",
+		"Access-Control-Request-Method": "POST",
+	})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+	w = doRequest(r, "DELETE", "/api/users", map[string]string{"Origin": "https://app.example.com"})
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("405 runs the group middlewares too, got %d %v", w.Code, w.Header())
+	}
+	w = doRequest(r, "OPTIONS", "/", map[string]string{
+		"Origin":                        "https://app.example.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("paths outside the group don't get CORS, got %d %v", w.Code, w.Header())
+	}
+}
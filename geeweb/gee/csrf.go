@@ -0,0 +1,202 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+type CSRFMode int
+
+const (
+	//CSRFDoubleSubmit keeps the token in a cookie, no server state needed
+	CSRFDoubleSubmit CSRFMode = iota
+	//CSRFSession keeps the token in the session, the sessions middleware
+	//has to run first
+	CSRFSession
+)
+
+type CSRFConfig struct {
+	Mode       CSRFMode
+	FieldName  string //form field, "csrf_token" by default
+	HeaderName string //header for scripts, "X-CSRF-Token" by default
+
+	//cookie of the double-submit mode, "csrf_token" on "/" by default
+	CookieName string
+	CookiePath string
+	Secure     bool
+	SameSite   http.SameSite //Lax by default
+}
+
+const (
+	csrfKey        = "gee/csrf"
+	csrfSessionKey = "_csrf"
+	csrfTokenSize  = 32
+)
+
+var (
+	errCSRF      = errors.New("gee: CSRF token missing or invalid")
+	errCSRFFuncs = errors.New("gee: csrfField and csrfToken need the CSRF middleware")
+)
+
+type csrfState struct {
+	token []byte
+	field string
+}
+
+//CSRF protects form routes with a token in a double-submit cookie, see CSRFWithConfig
+func CSRF() HandlerFunc {
+	return CSRFWithConfig(CSRFConfig{})
+}
+
+//CSRFWithConfig issues a token and checks it on POST, PUT, PATCH and DELETE,
+//from the form field or the header. Forms get it with {{csrfField}},
+//scripts with {{csrfToken}} or c.CSRFToken(). A missing or wrong token
+//stops the chain with 403 through the error handler.
+func CSRFWithConfig(conf CSRFConfig) HandlerFunc {
+	if conf.FieldName == "" {
+		conf.FieldName = "csrf_token"
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = "X-CSRF-Token"
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = "csrf_token"
+	}
+	if conf.CookiePath == "" {
+		conf.CookiePath = "/"
+	}
+	if conf.SameSite == 0 {
+		conf.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(c *Context) {
+		token, err := conf.token(c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Set(csrfKey, &csrfState{token: token, field: conf.FieldName})
+
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			sent := c.Req.Header.Get(conf.HeaderName)
+			if sent == "" {
+				sent = c.PostForm(conf.FieldName)
+			}
+			if !csrfValid(token, sent) {
+				c.AbortWithError(http.StatusForbidden, errCSRF)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+//token loads the token of the client, a new one is issued if there is none
+func (conf *CSRFConfig) token(c *Context) ([]byte, error) {
+	var session Session
+	if conf.Mode == CSRFSession {
+		value, ok := c.Get(SessionKey)
+		if !ok {
+			return nil, errors.New("gee: CSRF session mode needs the sessions middleware")
+		}
+		session = value.(Session)
+		if saved, ok := session.Get(csrfSessionKey).(string); ok {
+			if token, err := base64.RawURLEncoding.DecodeString(saved); err == nil && len(token) == csrfTokenSize {
+				return token, nil
+			}
+		}
+	} else if cookie, err := c.Req.Cookie(conf.CookieName); err == nil {
+		if token, err := base64.RawURLEncoding.DecodeString(cookie.Value); err == nil && len(token) == csrfTokenSize {
+			return token, nil
+		}
+	}
+
+	token := make([]byte, csrfTokenSize)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+	if session != nil {
+		session.Set(csrfSessionKey, encoded)
+	} else {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     conf.CookieName,
+			Value:    encoded,
+			Path:     conf.CookiePath,
+			Secure:   conf.Secure,
+			HttpOnly: true,
+			SameSite: conf.SameSite,
+		})
+	}
+	return token, nil
+}
+
+//csrfValid unmasks sent, see maskCSRF
+func csrfValid(token []byte, sent string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*csrfTokenSize {
+		return false
+	}
+	pad, xored := masked[:csrfTokenSize], masked[csrfTokenSize:]
+	unmasked := make([]byte, csrfTokenSize)
+	for i := range unmasked {
+		unmasked[i] = pad[i] ^ xored[i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
+
+//maskCSRF xors the token with a fresh pad, the page then differs on every
+//response and compression can't leak the token (BREACH)
+func maskCSRF(token []byte) string {
+	masked := make([]byte, 2*csrfTokenSize)
+	if _, err := io.ReadFull(rand.Reader, masked[:csrfTokenSize]); err != nil {
+		return ""
+	}
+	for i := range token {
+		masked[csrfTokenSize+i] = masked[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+//CSRFToken returns the token to send back in the form field or the header,
+//"" when the CSRF middleware isn't in the chain
+func (c *Context) CSRFToken() string {
+	state, ok := c.Get(csrfKey)
+	if !ok {
+		return ""
+	}
+	return maskCSRF(state.(*csrfState).token)
+}
+
+//csrfFuncs are in the funcMap of every template, they fail unless
+//Context.HTML binds them to the token of a request
+var csrfFuncs = template.FuncMap{
+	"csrfField": func() (template.HTML, error) { return "", errCSRFFuncs },
+	"csrfToken": func() (string, error) { return "", errCSRFFuncs },
+}
+
+//templates clones the engine templates with csrfField and csrfToken bound to
+//the token of the request, the shared templates never see it
+func (state *csrfState) templates(engine *Engine) (*template.Template, error) {
+	if engine.csrfTemplates == nil {
+		return engine.htmlTemplates, nil
+	}
+	tmpl, err := engine.csrfTemplates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	//one mask per response, the field and csrfToken carry the same value
+	token := maskCSRF(state.token)
+	field := template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(state.field) + `" value="` + token + `">`)
+	return tmpl.Funcs(template.FuncMap{
+		"csrfField": func() template.HTML { return field },
+		"csrfToken": func() string { return token },
+	}), nil
+}
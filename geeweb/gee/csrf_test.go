@@ -0,0 +1,146 @@
+package gee
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+//mapSession stands in for the sessions package, which imports gee
+type mapSession map[string]interface{}
+
+func (s mapSession) ID() string                        { return "test" }
+func (s mapSession) Get(key string) interface{}        { return s[key] }
+func (s mapSession) Set(key string, value interface{}) { s[key] = value }
+func (s mapSession) Delete(key string)                 { delete(s, key) }
+func (s mapSession) Clear()                            {}
+func (s mapSession) AddFlash(value interface{})        {}
+func (s mapSession) Flashes() []interface{}            { return nil }
+func (s mapSession) RenewID() error                    { return nil }
+func (s mapSession) Save() error                       { return nil }
+
+var csrfInput = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="([A-Za-z0-9_-]+)">`)
+
+func newCSRFEngine(t *testing.T, dir string, middlewares ...HandlerFunc) *Engine {
+	form := `<form method="post">{{csrfField}}</form><meta name="csrf" content="{{csrfToken}}">`
+	if err := ioutil.WriteFile(filepath.Join(dir, "form.tmpl"), []byte(form), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.Use(middlewares...)
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+	r.GET("/form", func(c *Context) { c.HTML(http.StatusOK, "form.tmpl", nil) })
+	r.POST("/form", func(c *Context) { c.String(http.StatusOK, "saved") })
+	return r
+}
+
+func postForm(r *Engine, cookies []*http.Cookie, form url.Values, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee-csrf")
+	defer os.RemoveAll(dir)
+	r := newCSRFEngine(t, dir, CSRF())
+
+	w := doRequest(r, "GET", "/form")
+	cookies := w.Result().Cookies()
+	m := csrfInput.FindStringSubmatch(w.Body.String())
+	if w.Code != http.StatusOK || len(cookies) != 1 || m == nil {
+		t.Fatalf("expected a token field and cookie, got %d %q %v", w.Code, w.Body.String(), cookies)
+	}
+	meta := regexp.MustCompile(`content="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if meta == nil || meta[1] != m[1] {
+		t.Fatalf("csrfToken renders the token of the field, got %q", w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(cookies[0])
+	again := httptest.NewRecorder()
+	r.ServeHTTP(again, req)
+	if n := csrfInput.FindStringSubmatch(again.Body.String()); n == nil || n[1] == m[1] || len(again.Result().Cookies()) != 0 {
+		t.Fatalf("every response masks the same token differently, got %q", again.Body.String())
+	}
+
+	if w = postForm(r, cookies, url.Values{"csrf_token": {m[1]}}, nil); w.Body.String() != "saved" {
+		t.Fatalf("form field: got %d %q", w.Code, w.Body.String())
+	}
+	if w = postForm(r, cookies, nil, map[string]string{"X-CSRF-Token": meta[1]}); w.Body.String() != "saved" {
+		t.Fatalf("header: got %d %q", w.Code, w.Body.String())
+	}
+
+	other := doRequest(r, "GET", "/form").Result().Cookies()
+	for name, check := range map[string]func() *httptest.ResponseRecorder{
+		"missing":      func() *httptest.ResponseRecorder { return postForm(r, cookies, nil, nil) },
+		"no cookie":    func() *httptest.ResponseRecorder { return postForm(r, nil, url.Values{"csrf_token": {m[1]}}, nil) },
+		"other cookie": func() *httptest.ResponseRecorder { return postForm(r, other, url.Values{"csrf_token": {m[1]}}, nil) },
+		"garbage":      func() *httptest.ResponseRecorder { return postForm(r, cookies, url.Values{"csrf_token": {"abc"}}, nil) },
+	} {
+		w = check()
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":403`) {
+			t.Fatalf("%s: expected 403 from the error handler, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCSRFTokenOnlyWhereTemplatesPutIt(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee-csrf")
+	defer os.RemoveAll(dir)
+	link := `<a href="{{.}}">next</a>{{csrfField}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "link.tmpl"), []byte(link), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := newCSRFEngine(t, dir, CSRF())
+	r.GET("/link", func(c *Context) { c.HTML(http.StatusOK, "link.tmpl", c.Query("next")) })
+
+	//data looking like a token placeholder is rendered as it is
+	w := doRequest(r, "GET", "/link?next="+url.QueryEscape("https://evil.example/?t=gee-csrf-token-marker"))
+	m := csrfInput.FindStringSubmatch(w.Body.String())
+	if m == nil || strings.Count(w.Body.String(), m[1]) != 1 || !strings.Contains(w.Body.String(), "t=gee-csrf-token-marker") {
+		t.Fatalf("the token belongs in the field only, got %q", w.Body.String())
+	}
+}
+
+func TestCSRFSession(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee-csrf")
+	defer os.RemoveAll(dir)
+	session := mapSession{}
+	r := newCSRFEngine(t, dir, func(c *Context) { c.Set(SessionKey, session) }, CSRFWithConfig(CSRFConfig{Mode: CSRFSession}))
+
+	w := doRequest(r, "GET", "/form")
+	m := csrfInput.FindStringSubmatch(w.Body.String())
+	if m == nil || len(w.Result().Cookies()) != 0 || session[csrfSessionKey] == nil {
+		t.Fatalf("the token lives in the session, got %q %v", w.Body.String(), w.Result().Cookies())
+	}
+	if w = postForm(r, nil, url.Values{"csrf_token": {m[1]}}, nil); w.Body.String() != "saved" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	delete(session, csrfSessionKey)
+	if w = postForm(r, nil, url.Values{"csrf_token": {m[1]}}, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("a new session has a new token, got %d", w.Code)
+	}
+}
+
+func TestCSRFFieldWithoutMiddleware(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee-csrf")
+	defer os.RemoveAll(dir)
+	r := newCSRFEngine(t, dir)
+	if w := doRequest(r, "GET", "/form"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("csrfField without CSRF must not render a marker, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,40 @@
+package gee
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const debugText = `<html>
+	<body>
+	<title>Gee Routes</title>
+	<table>
+	<th align=center>Method</th><th align=center>Pattern</th><th align=center>Handler</th><th align=center>Middlewares</th>
+	{{range .}}
+		<tr>
+		<td align=left>{{.Method}}</td>
+		<td align=left font=fixed>{{.Pattern}}</td>
+		<td align=left font=fixed>{{.Handler}}</td>
+		<td align=center>{{.Middlewares}}</td>
+		</tr>
+	{{end}}
+	</table>
+	</body>
+	</html>`
+
+var debug = template.Must(template.New("gee routes").Parse(debugText))
+
+//DebugRoutes renders the route table, as JSON when the client accepts it
+//or asks for ?format=json, otherwise as HTML. It is opt-in:
+//	r.GET("/debug/routes", r.DebugRoutes())
+func (engine *Engine)DebugRoutes() HandlerFunc{
+	return func(c *Context){
+		routes := engine.Routes()
+		if c.Query("format") == "json" || strings.Contains(c.Req.Header.Get("Accept"), "application/json") {
+			c.JSON(http.StatusOK, routes)
+			return
+		}
+		c.Render(http.StatusOK, HTML{Template: debug, Name: "gee routes", Data: routes})
+	}
+}
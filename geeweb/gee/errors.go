@@ -0,0 +1,82 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//HTTPError carries the status code and the message shown to the client,
+//Err is the internal cause and is never rendered
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func NewHTTPError(code int, message string) *HTTPError {
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+//ErrorHandler turns the errors collected with Context.Error into a response
+type ErrorHandler func(c *Context, errs []error)
+
+//defaultErrorHandler answers with the first HTTPError, 500 otherwise:
+//	{"code": 404, "error": "user not found"}
+func defaultErrorHandler(c *Context, errs []error) {
+	code, message := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	for _, err := range errs {
+		var he *HTTPError
+		if errors.As(err, &he) {
+			code, message = he.Code, he.Message
+			break
+		}
+	}
+	c.JSON(code, H{"code": code, "error": message})
+}
+
+//Error records err on the context, the engine error handler renders the
+//collected errors once the chain is done unless the handlers chose the
+//response themselves: a body was written or a status set, e.g. with
+//c.Status(204) or c.Redirect. The errors are kept for the logger then.
+func (c *Context) Error(err error) error {
+	if err != nil {
+		c.Errors = append(c.Errors, err)
+	}
+	return err
+}
+
+//AbortWithError records err with code and stops the chain
+func (c *Context) AbortWithError(code int, err error) error {
+	c.Abort()
+	return c.Error(&HTTPError{Code: code, Message: http.StatusText(code), Err: err})
+}
+
+//Fail stops the chain with code, obj is the message when it is not an error
+func (c *Context) Fail(code int, obj interface{}) {
+	if err, ok := obj.(error); ok {
+		c.AbortWithError(code, err)
+		return
+	}
+	c.Abort()
+	c.Error(&HTTPError{Code: code, Message: fmt.Sprint(obj)})
+}
+
+func (c *Context) handleErrors() {
+	if len(c.Errors) == 0 || c.Writer.Written() || c.writermem.statusSet || c.errorsHandled {
+		return
+	}
+	c.errorsHandled = true
+	c.engine.errorHandler(c, c.Errors)
+}
@@ -0,0 +1,142 @@
+package gee
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//ErrBodyTooLarge is returned by reads past the BodyLimit of a route,
+//passed to c.Error it renders a 413
+var ErrBodyTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+
+//BodyLimit caps the request body at limit bytes, for a route, a group or
+//the engine. A larger Content-Length is refused with 413 right away,
+//otherwise reading past the limit fails with ErrBodyTooLarge.
+//
+//	r.POST("/upload", gee.BodyLimit(10<<20), upload)
+func BodyLimit(limit int64) HandlerFunc {
+	return func(c *Context) {
+		if c.Req.ContentLength > limit {
+			c.SetHeader("Connection", "close")
+			c.Error(ErrBodyTooLarge)
+			c.Abort()
+			return
+		}
+		if c.Req.Body != nil && c.Req.Body != http.NoBody {
+			c.Req.Body = &limitedBody{ReadCloser: c.Req.Body, remaining: limit, ctx: c}
+		}
+		c.Next()
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+	ctx       *Context
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+	//read one byte more than allowed to tell a body of exactly limit bytes
+	//from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	//the rest of the body isn't read, the connection can't be reused
+	b.ctx.SetHeader("Connection", "close")
+	return n, ErrBodyTooLarge
+}
+
+//bodyError replaces err by ErrBodyTooLarge when the body went past BodyLimit,
+//parsers don't always keep the error of the underlying reader
+func (c *Context) bodyError(err error) error {
+	if body, ok := c.Req.Body.(*limitedBody); ok && body.exceeded {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+func (c *Context) maxMultipartMemory() int64 {
+	if c.engine != nil && c.engine.MaxMultipartMemory > 0 {
+		return c.engine.MaxMultipartMemory
+	}
+	return defaultMultipartMemory
+}
+
+//parseForm reads a form body once, multipart ones keep up to
+//Engine.MaxMultipartMemory in memory and spill files to disk
+func (c *Context) parseForm() error {
+	if c.Req.PostForm != nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = c.Req.ParseMultipartForm(c.maxMultipartMemory())
+	} else {
+		err = c.Req.ParseForm()
+	}
+	return c.bodyError(err)
+}
+
+//MultipartForm parses a multipart/form-data body, see Engine.MaxMultipartMemory
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.Req.MultipartForm != nil {
+		return c.Req.MultipartForm, nil
+	}
+	if err := c.Req.ParseMultipartForm(c.maxMultipartMemory()); err != nil {
+		return nil, c.bodyError(err)
+	}
+	return c.Req.MultipartForm, nil
+}
+
+//FormFile returns the first file uploaded as name, http.ErrMissingFile if there is none
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return files[0], nil
+}
+
+//SaveUploadedFile copies the upload to dst, missing directories are created.
+//fh.Filename comes from the client, don't use it in dst unchecked.
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
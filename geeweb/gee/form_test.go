@@ -0,0 +1,105 @@
+package gee
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newUpload(t *testing.T, content string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, err := mw.CreateFormFile("file", "report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload?title=query", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.MaxMultipartMemory = 8
+	r.POST("/upload", BodyLimit(1<<10), func(c *Context) {
+		fh, err := c.FormFile("file")
+		if err == ErrBodyTooLarge {
+			c.Error(err)
+			return
+		}
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		if _, err := c.FormFile("missing"); err != http.ErrMissingFile {
+			t.Errorf("expected ErrMissingFile, got %v", err)
+		}
+		if err := c.SaveUploadedFile(fh, filepath.Join(dir, "sub", fh.Filename)); err != nil {
+			c.Fail(http.StatusInternalServerError, err)
+			return
+		}
+		c.String(http.StatusOK, c.PostForm("title"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUpload(t, "a,b\n1,2\n"))
+	if w.Code != http.StatusOK || w.Body.String() != "report" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	saved, err := ioutil.ReadFile(filepath.Join(dir, "sub", "report.csv"))
+	if err != nil || string(saved) != "a,b\n1,2\n" {
+		t.Fatalf("saved file: %q %v", saved, err)
+	}
+
+	//too large, both with a known length and with a streamed body
+	req := newUpload(t, strings.Repeat("x", 2<<10))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a large Content-Length, got %d", w.Code)
+	}
+	req = newUpload(t, strings.Repeat("x", 2<<10))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "request body too large") {
+		t.Fatalf("expected 413 for a streamed body, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBindBodyLimit(t *testing.T) {
+	r := New()
+	r.POST("/login", BodyLimit(16), func(c *Context) {
+		var form struct {
+			Name string `form:"name"`
+		}
+		if c.Bind(&form) == nil {
+			c.String(http.StatusOK, form.Name)
+		}
+	})
+	for body, code := range map[string]int{
+		"name=gee":                        http.StatusOK,
+		"name=" + strings.Repeat("x", 64): http.StatusRequestEntityTooLarge,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatalf("%q: expected %d, got %d %s", body, code, w.Code, w.Body.String())
+		}
+	}
+}
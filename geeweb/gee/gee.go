@@ -1,22 +1,43 @@
 package gee
 
 import (
-	"fmt"
 	"html/template"
 	"net/http"
-	"path"
-	"strings"
+	"strconv"
+	"sync"
 )
 
 type HandlerFunc func(ctx *Context)
 
 type Engine struct {
 	*RouterGroup          //这个用来直接调用全匹配路径函数
+
+	//ForwardedByClientIP makes ClientIP trust X-Forwarded-For and X-Real-Ip,
+	//only turn it on behind a proxy that sets them
+	ForwardedByClientIP bool
+	//CheckWSOrigin decides whether a WebSocket handshake is accepted,
+	//nil only allows requests without Origin or from the same host
+	CheckWSOrigin func(r *http.Request) bool
+	//MaxMultipartMemory is how much of a multipart body is kept in memory,
+	//the rest of the files goes to temporary files. It doesn't cap the body
+	//size, use BodyLimit for that.
+	MaxMultipartMemory int64
+
 	router *router
 	groups []*RouterGroup //store all groups
 
 	htmlTemplates *template.Template
+	//csrfTemplates is never executed, html/template can only Clone such
+	//templates, see csrfState.templates
+	csrfTemplates *template.Template
 	funcMap template.FuncMap
+
+	pool sync.Pool //reuse Context and its Params between requests
+	namedRoutes map[string]*Route //for URLFor
+	routes []*Route //registration order, for Routes
+	errorHandler ErrorHandler
+
+	noRoute []HandlerFunc //answers 404 after the engine middlewares, rebuilt by Use
 }
 
 type RouterGroup struct{
@@ -24,11 +45,20 @@ type RouterGroup struct{
 	middlewares []HandlerFunc // support middleware
 	parent *RouterGroup //support nesting
 	engine *Engine
+	routed bool //a route was added to the group or a subgroup, see Use
+
+	//chains answering 405 and automatic OPTIONS on the paths of the group,
+	//built with its first own route
+	noMethod []HandlerFunc
+	allowOptions []HandlerFunc
 }
 
 func New()*Engine{
 	 engine := &Engine{
 		router: newRouter(),
+		namedRoutes: make(map[string]*Route),
+		errorHandler: defaultErrorHandler,
+		MaxMultipartMemory: defaultMultipartMemory,
 	 }
 	 engine.RouterGroup = &RouterGroup{
 	 	engine: engine,    //循环调用？？
@@ -36,15 +66,31 @@ func New()*Engine{
 	 engine.groups = []*RouterGroup{
 	 	engine.RouterGroup,
 	 }
+	 engine.noRoute = engine.combineHandlers([]HandlerFunc{notFound})
+	 engine.pool.New = func() interface{} {
+	 	return &Context{
+	 		engine: engine,
+	 		Params: make(Params, 0, engine.router.maxParams),
+		}
+	 }
 	 return engine
 }
 
+//SetErrorHandler replaces the default JSON error envelope
+func (engine *Engine)SetErrorHandler(handler ErrorHandler){
+	engine.errorHandler = handler
+}
+
 func (engine *Engine)SetFuncMap(funcMap template.FuncMap){
 	engine.funcMap = funcMap
 }
 
+//LoadHTMLGlob parses the templates, url (URLFor), csrfField and csrfToken
+//(see CSRF) are always available
 func (engine *Engine)LoadHTMLGlob(pattern string){
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+	builtin := template.FuncMap{"url": engine.URLFor}
+	engine.htmlTemplates = template.Must(template.New("").Funcs(builtin).Funcs(csrfFuncs).Funcs(engine.funcMap).ParseGlob(pattern))
+	engine.csrfTemplates = template.Must(engine.htmlTemplates.Clone())
 }
 
 func (group *RouterGroup)Group(prefix string)*RouterGroup{
@@ -58,59 +104,109 @@ func (group *RouterGroup)Group(prefix string)*RouterGroup{
 	return newGroup
 }
 
-func (group *RouterGroup)addRoute(method string, comp string, handler HandlerFunc){
+//combineHandlers resolves the full chain of a route once, at registration:
+//the middlewares of every ancestor group from the root down, then handlers.
+//Use refuses to change a group that has routes, so chains never go stale.
+func (group *RouterGroup)combineHandlers(handlers []HandlerFunc)[]HandlerFunc{
+	var groups []*RouterGroup
+	size := len(handlers)
+	for g := group; g != nil; g = g.parent {
+		groups = append(groups, g)
+		size += len(g.middlewares)
+	}
+
+	chain := make([]HandlerFunc, 0, size)
+	for i := len(groups) - 1; i >= 0; i-- {
+		chain = append(chain, groups[i].middlewares...)
+	}
+	return append(chain, handlers...)
+}
+
+//addRoute registers handlers for the route, all but the last one are
+//per-route middlewares, e.g. GET("/admin", auth, handler)
+func (group *RouterGroup)addRoute(method string, comp string, handlers []HandlerFunc)*Route{
 	pattern := group.prefix + comp
-	group.engine.router.addRoute(method, pattern, handler)
+	if len(handlers) == 0 {
+		panic("gee: no handler for " + method + " " + pattern)
+	}
+	chain := group.combineHandlers(handlers)
+	if group.noMethod == nil {
+		group.noMethod = group.combineHandlers([]HandlerFunc{methodNotAllowed})
+		group.allowOptions = group.combineHandlers([]HandlerFunc{autoOptions})
+	}
+	for g := group; g != nil; g = g.parent {
+		g.routed = true
+	}
+	if err := group.engine.router.addRoute(method, pattern, chain, group); err != nil {
+		panic(err)
+	}
+	route := &Route{Method: method, Pattern: pattern, engine: group.engine, handlers: chain}
+	group.engine.routes = append(group.engine.routes, route)
+	return route
 }
 
-func (group *RouterGroup)GET(pattern string, handler HandlerFunc){
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup)GET(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("GET", pattern, handlers)
 }
 
-func (group *RouterGroup)POST(pattern string, handler HandlerFunc){
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup)POST(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("POST", pattern, handlers)
 }
 
-func (group *RouterGroup)Run(addr string)(err error){
-	return http.ListenAndServe(addr, group.engine)
+func (group *RouterGroup)PUT(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("PUT", pattern, handlers)
 }
 
-func (group *RouterGroup)Use(middlewares...HandlerFunc){
-	group.middlewares = append(group.middlewares, middlewares...)
+func (group *RouterGroup)DELETE(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("DELETE", pattern, handlers)
 }
 
-func (group *RouterGroup)createStaticHandler(relativePath string, fs http.FileSystem)HandlerFunc{
-	absolutePath := path.Join(group.prefix, relativePath)
-	fmt.Println("absolutePath:", absolutePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(ctx *Context) {
-		file := ctx.Param("filepath")
-		if _, err := fs.Open(file); err != nil {
-			ctx.Status(http.StatusNotFound)
-			return
-		}
+func (group *RouterGroup)PATCH(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("PATCH", pattern, handlers)
+}
 
-		fileServer.ServeHTTP(ctx.Writer, ctx.Req)
-	}
+func (group *RouterGroup)HEAD(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("HEAD", pattern, handlers)
 }
 
-func (group *RouterGroup)Static(relativePath string, root string){
-	fmt.Println("relativePath:", relativePath, "root:", root)
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	fmt.Println("urlPattern:", urlPattern)
-	group.GET(urlPattern, handler)
+func (group *RouterGroup)OPTIONS(pattern string, handlers ...HandlerFunc)*Route{
+	return group.addRoute("OPTIONS", pattern, handlers)
 }
 
-func (engine *Engine)ServeHTTP(w http.ResponseWriter, r *http.Request){
-	var middlewares []HandlerFunc
-	for _, group := range engine.groups{
-		if strings.HasPrefix(r.URL.Path, group.prefix){
-			middlewares = append(middlewares, group.middlewares...)
+//Any registers the handler for every method in anyMethods,
+//the returned Route is the GET one which is enough to name the pattern
+func (group *RouterGroup)Any(pattern string, handlers ...HandlerFunc)*Route{
+	var route *Route
+	for _, method := range anyMethods{
+		if r := group.addRoute(method, pattern, handlers); route == nil {
+			route = r
 		}
 	}
-	c := newContext(w, r)
-	c.handlers = middlewares
-	c.engine = engine
+	return route
+}
+
+func (group *RouterGroup)Run(addr string)(err error){
+	return http.ListenAndServe(addr, group.engine)
+}
+
+//Use adds middlewares to the group and its subgroups. Chains are resolved
+//when a route is registered, so Use panics once the group or a subgroup
+//has routes: call it before GET, POST and the like.
+func (group *RouterGroup)Use(middlewares...HandlerFunc){
+	if group.routed {
+		panic("gee: Use on group " + strconv.Quote(group.prefix) + " after its routes were registered, the middlewares would not apply to them")
+	}
+	group.middlewares = append(group.middlewares, middlewares...)
+	if engine := group.engine; group == engine.RouterGroup {
+		//only the engine middlewares run when no route matches
+		engine.noRoute = engine.combineHandlers([]HandlerFunc{notFound})
+	}
+}
+
+func (engine *Engine)ServeHTTP(w http.ResponseWriter, r *http.Request){
+	c := engine.pool.Get().(*Context)
+	c.reset(w, r)
 	engine.router.handle(c)
+	c.Writer.WriteHeaderNow()
+	engine.pool.Put(c)
 }
\ No newline at end of file
@@ -0,0 +1,333 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//doRequest serves a bodyless request, header is optional
+func doRequest(engine *Engine, method string, path string, header ...map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for _, h := range header {
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestMethods(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "get %s", c.Param("id"))
+	})
+	r.PUT("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "put %s", c.Param("id"))
+	})
+	r.Any("/any", func(c *Context) {
+		c.String(http.StatusOK, c.Method)
+	})
+
+	if w := doRequest(r, "PUT", "/users/1"); w.Code != http.StatusOK || w.Body.String() != "put 1" {
+		t.Fatalf("PUT /users/1 got %d %q", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, "HEAD", "/users/1"); w.Code != http.StatusOK {
+		t.Fatalf("HEAD should fall back to GET, got %d", w.Code)
+	}
+	for _, method := range anyMethods {
+		if w := doRequest(r, method, "/any"); w.Code != http.StatusOK || w.Body.String() != method {
+			t.Fatalf("Any %s got %d %q", method, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {})
+	r.DELETE("/users/:id", func(c *Context) {})
+
+	w := doRequest(r, "POST", "/users/1")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	w = doRequest(r, "OPTIONS", "/users/1")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") == "" {
+		t.Fatalf("OPTIONS should be answered automatically, got %d", w.Code)
+	}
+
+	if w = doRequest(r, "POST", "/nothing"); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", w.Code)
+	}
+}
+
+func TestMethodNotAllowedAfterSubgroupRoutes(t *testing.T) {
+	var trace []string
+	r := New()
+	v1 := r.Group("/v1")
+	v1.Use(func(c *Context) { trace = append(trace, "v1") })
+	//the subgroups register first, the parents still answer 405 and OPTIONS
+	r.Group("/g").GET("/b", func(c *Context) {})
+	v1.Group("/x").GET("/y", func(c *Context) {})
+	v1.GET("/a", func(c *Context) {})
+	r.GET("/c", func(c *Context) {})
+
+	for _, path := range []string{"/v1/a", "/c"} {
+		trace = nil
+		if w := doRequest(r, "POST", path); w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("POST %s: expect 405, got %d", path, w.Code)
+		}
+		if w := doRequest(r, "OPTIONS", path); w.Code != http.StatusNoContent {
+			t.Fatalf("OPTIONS %s: expect 204, got %d", path, w.Code)
+		}
+		if expect := map[string]int{"/v1/a": 2, "/c": 0}[path]; len(trace) != expect {
+			t.Fatalf("%s ran the v1 middleware %d times, expect %d", path, len(trace), expect)
+		}
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+	v1.GET("/users/:id{int}", func(c *Context) {}).Name("user.show")
+	v1.GET("/files/*filepath", func(c *Context) {}).Name("file")
+	r.GET("/", func(c *Context) {}).Name("home")
+
+	cases := []struct {
+		name  string
+		pairs []interface{}
+		url   string
+	}{
+		{"user.show", []interface{}{"id", 42}, "/v1/users/42"},
+		{"user.show", []interface{}{"id", 42, "tab", "a b"}, "/v1/users/42?tab=a+b"},
+		{"file", []interface{}{"filepath", "css/a b.css"}, "/v1/files/css/a%20b.css"},
+		{"home", nil, "/"},
+	}
+	for _, c := range cases {
+		if url, err := r.URLFor(c.name, c.pairs...); err != nil || url != c.url {
+			t.Fatalf("URLFor %s %v = %q, %v; expect %s", c.name, c.pairs, url, err, c.url)
+		}
+	}
+
+	for _, pairs := range [][]interface{}{nil, {"id"}, {"id", "abc"}} {
+		if _, err := r.URLFor("user.show", pairs...); err == nil {
+			t.Fatalf("URLFor user.show %v should fail", pairs)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "link.tmpl"), []byte(`{{url "user.show" "id" .}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+	r.GET("/link/:id", func(c *Context) {
+		c.HTML(http.StatusOK, "link.tmpl", c.Param("id"))
+	})
+	if w := doRequest(r, "GET", "/link/7"); w.Body.String() != "/v1/users/7" {
+		t.Fatalf("url template func got %q", w.Body.String())
+	}
+}
+
+func listUsers(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {})
+	v1 := r.Group("/v1")
+	v1.Use(func(c *Context) {})
+	v1.GET("/users", listUsers)
+	r.POST("/login", func(c *Context) {})
+	r.GET("/debug/routes", r.DebugRoutes())
+
+	routes := r.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expect 3 routes, got %d", len(routes))
+	}
+	expect := RouteInfo{Method: "GET", Pattern: "/v1/users", Handler: "geeweb/gee.listUsers", Middlewares: 2}
+	if routes[0] != expect {
+		t.Fatalf("expect %+v, got %+v", expect, routes[0])
+	}
+	if routes[1].Method != "POST" || routes[1].Pattern != "/login" || routes[1].Middlewares != 1 {
+		t.Fatalf("unexpected %+v", routes[1])
+	}
+
+	w := doRequest(r, "GET", "/debug/routes?format=json")
+	var got []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || !reflect.DeepEqual(got, routes) {
+		t.Fatalf("debug json got %s, %v", w.Body.String(), err)
+	}
+	w = doRequest(r, "GET", "/debug/routes")
+	if !strings.Contains(w.Body.String(), "/v1/users") || w.Header().Get("Content-Type") != "text/html" {
+		t.Fatalf("debug html got %s", w.Body.String())
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+		}
+	}
+
+	r := New()
+	r.Use(mark("engine"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	admin := v1.Group("/admin")
+	admin.Use(mark("admin"))
+	admin.GET("/users", mark("auth"), mark("handler"))
+	r.GET("/v10/users", mark("v10"))
+
+	cases := map[string]string{
+		"/v1/admin/users": "[engine v1 admin auth handler]",
+		"/v10/users":      "[engine v10]",
+		"/v1/missing":     "[engine]",
+	}
+	for path, expect := range cases {
+		trace = nil
+		doRequest(r, "GET", path)
+		if fmt.Sprint(trace) != expect {
+			t.Fatalf("%s ran %v, expect %s", path, trace, expect)
+		}
+	}
+
+	for name, group := range map[string]*RouterGroup{"engine": r.RouterGroup, "v1": v1, "admin": admin} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Use on %s after its routes should panic", name)
+				}
+			}()
+			group.Use(mark("late"))
+		}()
+	}
+	r.Group("/v2").Use(mark("v2"))
+}
+
+func TestAbortAndKeys(t *testing.T) {
+	auth := func(c *Context) {
+		if c.Query("token") != "secret" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"error": "unauthorized"})
+			return
+		}
+		c.Set("user", "geek")
+		c.Set("uid", 7)
+	}
+	var after bool
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		after = c.IsAborted()
+	})
+	r.GET("/me", auth, func(c *Context) {
+		if _, ok := c.Deadline(); ok || c.Err() != nil {
+			t.Fatal("request context shouldn't be done")
+		}
+		c.String(http.StatusOK, "%s %d %v", c.MustGet("user"), c.GetInt("uid"), c.Value("user"))
+	})
+
+	w := doRequest(r, "GET", "/me")
+	if w.Code != http.StatusUnauthorized || !after || !strings.Contains(w.Body.String(), "unauthorized") {
+		t.Fatalf("expect aborted 401, got %d %s", w.Code, w.Body.String())
+	}
+	w = doRequest(r, "GET", "/me?token=secret")
+	if w.Code != http.StatusOK || after || w.Body.String() != "geek 7 geek" {
+		t.Fatalf("expect 200, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	var copied *Context
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		c.Set("user", c.Param("id"))
+		copied = c.Copy()
+		c.String(http.StatusOK, "ok")
+	})
+
+	doRequest(r, "GET", "/users/1")
+	first := copied
+	doRequest(r, "GET", "/users/2?q=x")
+	if first.Param("id") != "1" || first.GetString("user") != "1" || first.Value("user") != "1" || first.Req.URL.RawQuery != "" {
+		t.Fatalf("the copy must not see the next request, got %s %v", first.Params, first.Keys)
+	}
+	if first.Err() != nil || !first.IsAborted() {
+		t.Fatal("the copy keeps the request context and runs no handlers")
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var logged int
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		logged = c.Writer.Status()
+	}, RecoveryWithWriter(nil))
+	r.GET("/missing", func(c *Context) {
+		c.Error(NewHTTPError(http.StatusNotFound, "user not found"))
+	})
+	r.GET("/written", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+		c.Error(errors.New("after the response"))
+	})
+
+	//a status alone is a response too, the error only goes to the logger
+	r.GET("/deleted", func(c *Context) {
+		c.Error(errors.New("cleanup failed"))
+		c.Status(http.StatusNoContent)
+	})
+	r.POST("/moved", func(c *Context) {
+		c.Error(errors.New("cache miss"))
+		c.Redirect(http.StatusSeeOther, "/")
+	})
+	r.GET("/panic", func(c *Context) {
+		c.Status(http.StatusCreated)
+		panic("boom")
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/missing", 404, `{"code":404,"error":"user not found"}`},
+		{"/written", 200, "ok"},
+		{"/deleted", 204, ""},
+		{"/panic", 500, `{"code":500,"error":"Internal Server Error"}`},
+	}
+	for _, c := range cases {
+		w := doRequest(r, "GET", c.path)
+		if w.Code != c.code || logged != c.code || strings.TrimSpace(w.Body.String()) != c.body {
+			t.Fatalf("%s: got %d (logged %d) %s", c.path, w.Code, logged, w.Body.String())
+		}
+	}
+	if w := doRequest(r, "POST", "/moved"); w.Code != http.StatusSeeOther || w.Body.Len() != 0 {
+		t.Fatalf("redirect got %d %s", w.Code, w.Body.String())
+	}
+
+	r.SetErrorHandler(func(c *Context, errs []error) {
+		c.String(http.StatusTeapot, "%d errors", len(errs))
+	})
+	if w := doRequest(r, "GET", "/missing"); w.Code != http.StatusTeapot || w.Body.String() != "1 errors" {
+		t.Fatalf("custom handler got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,14 +1,177 @@
 package gee
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/template"
 	"time"
 )
 
+//LogParams is what a LogFormatter gets for every request
+type LogParams struct {
+	TimeStamp time.Time
+	ClientIP  string
+	Method    string
+	Path      string //RequestURI, query included
+	Proto     string
+	Status    int
+	Bytes     int
+	Latency   time.Duration
+	UserAgent string
+	Referer   string
+	RequestID string
+	User      string //c.GetString(UserKey), "" for anonymous requests
+	Errors    []error
+}
+
+//LogFormatter renders one log line, the trailing newline included
+type LogFormatter func(params LogParams) string
+
+type LoggerConfig struct {
+	Output    io.Writer    //os.Stdout by default
+	Formatter LogFormatter //CommonFormatter by default
+	SkipPaths []string     //exact paths that are not logged, e.g. /healthz
+	//RequestIDHeader is looked up in the response headers first, then in the
+	//request, X-Request-Id by default
+	RequestIDHeader string
+}
+
+const apacheTime = "02/Jan/2006:15:04:05 -0700"
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+//CommonFormatter writes the Apache common log format
+func CommonFormatter(p LogParams) string {
+	size := "-"
+	if p.Bytes > 0 {
+		size = fmt.Sprint(p.Bytes)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s\n",
+		p.ClientIP, orDash(p.User), p.TimeStamp.Format(apacheTime),
+		p.Method, p.Path, p.Proto, p.Status, size)
+}
+
+//CombinedFormatter is the common format plus referer and user agent
+func CombinedFormatter(p LogParams) string {
+	line := CommonFormatter(p)
+	return fmt.Sprintf("%s %q %q\n", line[:len(line)-1], orDash(p.Referer), orDash(p.UserAgent))
+}
+
+//JSONFormatter writes one JSON object per line, latency in milliseconds
+func JSONFormatter(p LogParams) string {
+	errs := make([]string, 0, len(p.Errors))
+	for _, err := range p.Errors {
+		errs = append(errs, err.Error())
+	}
+	line, _ := json.Marshal(struct {
+		Time      string   `json:"time"`
+		ClientIP  string   `json:"client_ip"`
+		Method    string   `json:"method"`
+		Path      string   `json:"path"`
+		Proto     string   `json:"proto"`
+		Status    int      `json:"status"`
+		Bytes     int      `json:"bytes"`
+		LatencyMS float64  `json:"latency_ms"`
+		UserAgent string   `json:"user_agent,omitempty"`
+		Referer   string   `json:"referer,omitempty"`
+		RequestID string   `json:"request_id,omitempty"`
+		User      string   `json:"user,omitempty"`
+		Errors    []string `json:"errors,omitempty"`
+	}{
+		Time:      p.TimeStamp.Format(time.RFC3339Nano),
+		ClientIP:  p.ClientIP,
+		Method:    p.Method,
+		Path:      p.Path,
+		Proto:     p.Proto,
+		Status:    p.Status,
+		Bytes:     p.Bytes,
+		LatencyMS: float64(p.Latency) / float64(time.Millisecond),
+		UserAgent: p.UserAgent,
+		Referer:   p.Referer,
+		RequestID: p.RequestID,
+		User:      p.User,
+		Errors:    errs,
+	})
+	return string(line) + "\n"
+}
+
+//TemplateFormatter renders LogParams with a text/template, a newline is
+//added when the template doesn't end with one. It panics on a bad template.
+//	TemplateFormatter("{{.Status}} {{.Method}} {{.Path}} {{.Latency}}")
+func TemplateFormatter(text string) LogFormatter {
+	tmpl := template.Must(template.New("gee log").Parse(text))
+	return func(p LogParams) string {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, p); err != nil {
+			return fmt.Sprintf("gee: log template: %v\n", err)
+		}
+		if b := buf.Bytes(); len(b) == 0 || b[len(b)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		return buf.String()
+	}
+}
+
 func Logger() HandlerFunc{
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc{
+	out := conf.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	formatter := conf.Formatter
+	if formatter == nil {
+		formatter = CommonFormatter
+	}
+	requestIDHeader := conf.RequestIDHeader
+	if requestIDHeader == "" {
+		requestIDHeader = "X-Request-Id"
+	}
+	skip := make(map[string]bool, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = true
+	}
+	var mu sync.Mutex
+
 	return func(c *Context){
 		t := time.Now()
 		c.Next()
-		fmt.Printf("star.chen [%d] %s in %v\n", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		if skip[c.Path] {
+			return
+		}
+
+		requestID := c.Writer.Header().Get(requestIDHeader)
+		if requestID == "" {
+			requestID = c.Req.Header.Get(requestIDHeader)
+		}
+		line := formatter(LogParams{
+			TimeStamp: t,
+			ClientIP:  c.ClientIP(),
+			Method:    c.Method,
+			Path:      c.Req.RequestURI,
+			Proto:     c.Req.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     c.Writer.Size(),
+			Latency:   time.Since(t),
+			UserAgent: c.Req.UserAgent(),
+			Referer:   c.Req.Referer(),
+			RequestID: requestID,
+			User:      c.GetString(UserKey),
+			Errors:    c.Errors,
+		})
+		mu.Lock()
+		io.WriteString(out, line)
+		mu.Unlock()
 	}
 }
@@ -0,0 +1,61 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func serveLogged(conf LoggerConfig, path string) string {
+	var out bytes.Buffer
+	conf.Output = &out
+	r := New()
+	r.ForwardedByClientIP = true
+	r.Use(LoggerWithConfig(conf))
+	r.GET("/hello", func(c *Context) {
+		c.Writer.Header().Set("X-Request-Id", "req-1")
+		c.Writer.Write([]byte("hello"))
+	})
+	r.GET("/healthz", func(c *Context) {})
+
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("User-Agent", "gee-test")
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	return out.String()
+}
+
+func TestLoggerFormats(t *testing.T) {
+	common := serveLogged(LoggerConfig{}, "/hello?a=1")
+	if ok, _ := regexp.MatchString(`^10\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:[\d:]{8} [+-]\d{4}\] "GET /hello\?a=1 HTTP/1\.1" 200 5\n$`, common); !ok {
+		t.Fatalf("unexpected common line %q", common)
+	}
+
+	combined := serveLogged(LoggerConfig{Formatter: CombinedFormatter}, "/hello")
+	if ok, _ := regexp.MatchString(`" 200 5 "http://example.com/" "gee-test"\n$`, combined); !ok {
+		t.Fatalf("unexpected combined line %q", combined)
+	}
+
+	var entry map[string]interface{}
+	line := serveLogged(LoggerConfig{Formatter: JSONFormatter}, "/hello")
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("invalid json line %q: %v", line, err)
+	}
+	if entry["client_ip"] != "10.0.0.1" || entry["status"] != float64(http.StatusOK) ||
+		entry["bytes"] != float64(5) || entry["request_id"] != "req-1" || entry["user_agent"] != "gee-test" {
+		t.Fatalf("unexpected json line %q", line)
+	}
+
+	custom := serveLogged(LoggerConfig{Formatter: TemplateFormatter("{{.Status}} {{.Method}} {{.Path}}")}, "/hello")
+	if custom != "200 GET /hello\n" {
+		t.Fatalf("unexpected custom line %q", custom)
+	}
+
+	if skipped := serveLogged(LoggerConfig{SkipPaths: []string{"/healthz"}}, "/healthz"); skipped != "" {
+		t.Fatalf("/healthz should be skipped, got %q", skipped)
+	}
+}
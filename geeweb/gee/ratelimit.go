@@ -0,0 +1,267 @@
+package gee
+
+import (
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	//TokenBucket allows bursts of Limit requests, refilled evenly over Window
+	TokenBucket RateLimitAlgorithm = iota
+	//SlidingWindow allows Limit requests in any Window, estimated from the
+	//counts of the current and the previous fixed window
+	SlidingWindow
+)
+
+//RateLimitRule is the limit a Store enforces for one key
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+}
+
+//RateLimitResult is the outcome of one request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//Reset is when the key is back to its full allowance
+	Reset time.Duration
+	//RetryAfter is when a denied request can be tried again
+	RetryAfter time.Duration
+}
+
+//Store keeps rate limit counters, Take counts one request for key.
+//Keys of different rules must not collide, see RateLimitConfig.Name.
+type Store interface {
+	Take(key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+//KeyFunc picks the bucket of a request, "" lets the request through
+type KeyFunc func(c *Context) string
+
+//KeyByIP limits every client address on its own
+func KeyByIP(c *Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+//KeyByHeader limits by the value of header, e.g. an API key,
+//requests without it are limited by IP
+func KeyByHeader(header string) KeyFunc {
+	return func(c *Context) string {
+		if value := c.Req.Header.Get(header); value != "" {
+			return "header:" + value
+		}
+		return KeyByIP(c)
+	}
+}
+
+//KeyByUser limits by the authenticated user stored under UserKey,
+//anonymous requests are limited by IP
+func KeyByUser(c *Context) string {
+	if user := c.GetString(UserKey); user != "" {
+		return "user:" + user
+	}
+	return KeyByIP(c)
+}
+
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm //TokenBucket by default
+	Limit     int
+	Window    time.Duration
+	Key       KeyFunc //KeyByIP by default
+	Store     Store   //a new MemoryStore by default
+	//Name prefixes the keys, set it when limiters share a Store
+	Name string
+	//StoreError is told about Store failures, the request goes through
+	//anyway. The error is logged by default.
+	StoreError func(c *Context, err error)
+}
+
+//RateLimit answers 429 with Retry-After once a key went over its limit,
+//allowed requests get X-RateLimit-Limit, -Remaining and -Reset. When the
+//Store fails the request goes through unlimited, see StoreError.
+//
+//	api.Use(gee.RateLimit(gee.RateLimitConfig{Limit: 100, Window: time.Minute}))
+func RateLimit(conf RateLimitConfig) HandlerFunc {
+	if conf.Limit <= 0 || conf.Window <= 0 {
+		panic("gee: RateLimit needs a positive Limit and Window")
+	}
+	key := conf.Key
+	if key == nil {
+		key = KeyByIP
+	}
+	store := conf.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	storeError := conf.StoreError
+	if storeError == nil {
+		storeError = logStoreError
+	}
+	rule := RateLimitRule{Algorithm: conf.Algorithm, Limit: conf.Limit, Window: conf.Window}
+	limit := strconv.Itoa(conf.Limit)
+
+	return func(c *Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+		res, err := store.Take(conf.Name+k, rule)
+		if err != nil {
+			storeError(c, err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", limit)
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			c.Fail(http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		c.Next()
+	}
+}
+
+//logStoreError keeps the error off c.Errors, the error handler would
+//answer 500 for a request that is let through
+func logStoreError(c *Context, err error) {
+	log.Printf("[RateLimit] %s %s: store error: %v", c.Method, c.Path, err)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+const memoryStoreShards = 64
+
+//MemoryStore is an in-process Store, keys are spread over shards with their
+//own lock and idle keys are dropped as the shards are used
+type MemoryStore struct {
+	shards [memoryStoreShards]memoryShard
+	now    func() time.Time
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateEntry
+	lastSweep time.Time
+}
+
+type rateEntry struct {
+	//token bucket
+	tokens float64
+	last   time.Time
+	//sliding window
+	start     time.Time
+	prev      int
+	curr      int
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*rateEntry)
+	}
+	return s
+}
+
+func (s *MemoryStore) Take(key string, rule RateLimitRule) (RateLimitResult, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%memoryStoreShards]
+	now := s.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.lastSweep) > time.Minute {
+		shard.sweep(now)
+	}
+	e := shard.entries[key]
+	if e == nil {
+		e = &rateEntry{tokens: float64(rule.Limit), last: now, start: now.Truncate(rule.Window)}
+		shard.entries[key] = e
+	}
+	if rule.Algorithm == SlidingWindow {
+		return e.slidingWindow(rule, now), nil
+	}
+	return e.tokenBucket(rule, now), nil
+}
+
+func (shard *memoryShard) sweep(now time.Time) {
+	shard.lastSweep = now
+	for key, e := range shard.entries {
+		if now.After(e.expiresAt) {
+			delete(shard.entries, key)
+		}
+	}
+}
+
+func (e *rateEntry) tokenBucket(rule RateLimitRule, now time.Time) RateLimitResult {
+	limit := float64(rule.Limit)
+	perToken := rule.Window / time.Duration(rule.Limit)
+	e.tokens = math.Min(limit, e.tokens+float64(now.Sub(e.last))/float64(perToken))
+	e.last = now
+
+	res := RateLimitResult{Limit: rule.Limit}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - e.tokens) * float64(perToken))
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = time.Duration((limit - e.tokens) * float64(perToken))
+	e.expiresAt = now.Add(res.Reset)
+	return res
+}
+
+func (e *rateEntry) slidingWindow(rule RateLimitRule, now time.Time) RateLimitResult {
+	if elapsed := now.Sub(e.start); elapsed >= rule.Window {
+		if elapsed < 2*rule.Window {
+			e.prev = e.curr
+		} else {
+			e.prev = 0
+		}
+		e.curr = 0
+		e.start = now.Truncate(rule.Window)
+	}
+	elapsed := now.Sub(e.start)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	estimate := float64(e.prev)*weight + float64(e.curr)
+
+	res := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimate+1 <= float64(rule.Limit) {
+		e.curr++
+		estimate++
+		res.Allowed = true
+	} else if e.curr+1 > rule.Limit || e.prev == 0 {
+		res.RetryAfter = rule.Window - elapsed
+	} else {
+		//wait until enough of the previous window slid out
+		free := float64(rule.Limit-1-e.curr) / float64(e.prev)
+		res.RetryAfter = time.Duration((1-free)*float64(rule.Window)) - elapsed
+	}
+	res.Remaining = rule.Limit - int(math.Ceil(estimate))
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	if e.curr > 0 {
+		res.Reset += rule.Window
+	}
+	e.expiresAt = e.start.Add(2 * rule.Window)
+	return res
+}
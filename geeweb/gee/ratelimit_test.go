@@ -0,0 +1,139 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time             { return f.t }
+func (f *fakeClock) add(d time.Duration)        { f.t = f.t.Add(d) }
+func newFakeClock() *fakeClock                  { return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)} }
+func allowed(res RateLimitResult, _ error) bool { return res.Allowed }
+
+func TestTokenBucket(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	store.now = clock.now
+	rule := RateLimitRule{Algorithm: TokenBucket, Limit: 3, Window: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		if !allowed(store.Take("k", rule)) {
+			t.Fatalf("burst request %d should pass", i)
+		}
+	}
+	res, _ := store.Take("k", rule)
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != time.Second {
+		t.Fatalf("expected a denial with 1s to wait, got %+v", res)
+	}
+	if !allowed(store.Take("other", rule)) {
+		t.Fatal("keys are limited separately")
+	}
+	clock.add(time.Second)
+	if !allowed(store.Take("k", rule)) || allowed(store.Take("k", rule)) {
+		t.Fatal("one token comes back per second")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	store.now = clock.now
+	rule := RateLimitRule{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}
+
+	for i := 0; i < 4; i++ {
+		if !allowed(store.Take("k", rule)) {
+			t.Fatalf("request %d should pass", i)
+		}
+	}
+	if res, _ := store.Take("k", rule); res.Allowed || res.RetryAfter != 10*time.Second {
+		t.Fatalf("expected to wait for the window end, got %+v", res)
+	}
+	//halfway into the next window half of the previous count still weighs
+	clock.add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		if !allowed(store.Take("k", rule)) {
+			t.Fatalf("request %d of the next window should pass", i)
+		}
+	}
+	res, _ := store.Take("k", rule)
+	if res.Allowed || res.RetryAfter != 2500*time.Millisecond {
+		t.Fatalf("expected to wait 2.5s, got %+v", res)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	store.now = clock.now
+	rule := RateLimitRule{Limit: 1, Window: time.Second}
+	store.Take("idle", rule)
+	clock.add(2 * time.Minute)
+	store.Take("idle", rule)
+	clock.add(2 * time.Minute)
+	for i := range store.shards {
+		store.shards[i].mu.Lock()
+		store.shards[i].sweep(clock.now())
+		n := len(store.shards[i].entries)
+		store.shards[i].mu.Unlock()
+		if n != 0 {
+			t.Fatalf("idle keys should be dropped, shard %d has %d", i, n)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	r := New()
+	public := r.Group("/public")
+	public.Use(RateLimit(RateLimitConfig{Limit: 2, Window: time.Minute, Key: KeyByHeader("X-API-Key")}))
+	public.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	r.GET("/private", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	get := func(path string, key string) *httptest.ResponseRecorder {
+		return doRequest(r, "GET", path, map[string]string{"X-API-Key": key})
+	}
+	w := get("/public/ping", "a")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+	get("/public/ping", "a")
+	w = get("/public/ping", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("expected 429, got %d %v", w.Code, w.Header())
+	}
+	if w = get("/public/ping", "b"); w.Code != http.StatusOK {
+		t.Fatalf("another key has its own bucket, got %d", w.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if w = get("/private", "a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("other groups aren't limited, got %d", w.Code)
+		}
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(key string, rule RateLimitRule) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store down")
+}
+
+func TestRateLimitStoreError(t *testing.T) {
+	var reported error
+	r := New()
+	r.Use(RateLimit(RateLimitConfig{Limit: 1, Window: time.Minute, Store: failingStore{},
+		StoreError: func(c *Context, err error) { reported = err }}))
+	r.DELETE("/items/:id", func(c *Context) {
+		if len(c.Errors) != 0 {
+			t.Errorf("store errors must not reach the error handler, got %v", c.Errors)
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	if w := doRequest(r, "DELETE", "/items/1"); w.Code != http.StatusNoContent || w.Body.Len() != 0 || reported == nil {
+		t.Fatalf("the request goes through, got %d %q (reported %v)", w.Code, w.Body.String(), reported)
+	}
+}
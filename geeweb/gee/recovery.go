@@ -1,37 +1,100 @@
 package gee
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
 	"runtime"
 	"strings"
+	"syscall"
 )
 
+//RecoveryFunc answers a request whose handler panicked with err
+type RecoveryFunc func(c *Context, err interface{})
+
+//trace skips runtime.Callers, trace and the deferred func of Recovery
 func trace(message string)string{
 	var pcs [32]uintptr
-	n, _, _, _ := runtime.Caller(3/*, pcs[:]*/)
+	n := runtime.Callers(3, pcs[:])
 
 	var str strings.Builder
 	str.WriteString(message + "\nTraceback:")
-	for _, pc := range pcs[:n]{
-		fn := runtime.FuncForPC(pc)
-		file, line := fn.FileLine(pc)
-		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		str.WriteString(fmt.Sprintf("\n\t%s\n\t\t%s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
 	}
 	return str.String()
 }
 
+//isBrokenPipe reports a panic caused by the client going away,
+//writing a 500 to that connection would only fail again
+func isBrokenPipe(err interface{}) bool{
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	return errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET)
+}
+
+func defaultHandleRecovery(ctx *Context, err interface{}){
+	ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+}
+
+//Recovery logs panics with their stack to stderr and answers 500
+//through the engine error handler
 func Recovery() HandlerFunc{
+	return RecoveryWithWriter(os.Stderr)
+}
+
+//RecoveryWithWriter is Recovery logging to out, nil disables the log
+func RecoveryWithWriter(out io.Writer) HandlerFunc{
+	return recoveryWith(out, defaultHandleRecovery)
+}
+
+//RecoveryWithHandler lets handle answer the request, e.g. to report the
+//panic somewhere else first. It isn't called for broken pipes.
+func RecoveryWithHandler(handle RecoveryFunc) HandlerFunc{
+	return recoveryWith(os.Stderr, handle)
+}
+
+func recoveryWith(out io.Writer, handle RecoveryFunc) HandlerFunc{
+	var logger *log.Logger
+	if out != nil {
+		logger = log.New(out, "", log.LstdFlags)
+	}
 	return func(ctx *Context) {
 		defer func(){
-			if err := recover(); err != nil{
-				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+			err := recover()
+			if err == nil {
+				return
 			}
+			if err == http.ErrAbortHandler {
+				//net/http aborts the response silently for this one
+				panic(err)
+			}
+
+			if isBrokenPipe(err) {
+				if logger != nil {
+					logger.Printf("[Recovery] %s %s broken pipe: %v\n", ctx.Method, ctx.Path, err)
+				}
+				ctx.Abort()
+				return
+			}
+			if logger != nil {
+				message := fmt.Sprintf("[Recovery] %s %s panic: %v", ctx.Method, ctx.Path, err)
+				logger.Printf("%s\n\n", trace(message))
+			}
+			//the status set before the panic doesn't stand, the error handler answers
+			ctx.writermem.statusSet = false
+			handle(ctx, err)
 		}()
 		ctx.Next()
 	}
-
-}
\ No newline at end of file
+}
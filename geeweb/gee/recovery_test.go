@@ -0,0 +1,65 @@
+package gee
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	var out bytes.Buffer
+	var logged int
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		logged = c.Writer.Status()
+	}, RecoveryWithWriter(&out))
+	r.GET("/panic", func(c *Context) {
+		names := []string{"geektutu"}
+		c.String(http.StatusOK, names[100])
+	})
+	r.GET("/pipe", func(c *Context) {
+		panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	})
+
+	w := doRequest(r, "GET", "/panic")
+	if w.Code != http.StatusInternalServerError || logged != http.StatusInternalServerError {
+		t.Fatalf("expect 500, got %d (logged %d)", w.Code, logged)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"code":500,"error":"Internal Server Error"}` {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+	log := out.String()
+	for _, expect := range []string{"GET /panic panic: runtime error: index out of range", "Traceback:", "gee.TestRecovery.func2", "recovery_test.go"} {
+		if !strings.Contains(log, expect) {
+			t.Fatalf("log should contain %q:\n%s", expect, log)
+		}
+	}
+
+	out.Reset()
+	w = doRequest(r, "GET", "/pipe")
+	if w.Body.Len() != 0 || !strings.Contains(out.String(), "broken pipe") || strings.Contains(out.String(), "Traceback") {
+		t.Fatalf("broken pipe shouldn't be answered, got %d %s, log %s", w.Code, w.Body.String(), out.String())
+	}
+}
+
+func TestRecoveryWithHandler(t *testing.T) {
+	var reported interface{}
+	r := New()
+	r.Use(RecoveryWithHandler(func(c *Context, err interface{}) {
+		reported = err
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, H{"error": "try later"})
+	}))
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	w := doRequest(r, "GET", "/panic")
+	if reported != "boom" || w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("custom handler not used: %v %d", reported, w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MIMEJSON       = "application/json"
+	MIMEJavaScript = "application/javascript"
+	MIMEXML        = "application/xml"
+	MIMEYAML       = "application/x-yaml"
+	MIMEMsgPack    = "application/x-msgpack"
+	MIMEProtoBuf   = "application/x-protobuf"
+	MIMEHTML       = "text/html"
+	MIMEPlain      = "text/plain"
+)
+
+//Render writes a response body, see Context.Render
+type Render interface {
+	//ContentType is set on the response, "" leaves the header alone
+	ContentType() string
+	Render(w io.Writer) error
+}
+
+type JSON struct {
+	Data interface{}
+}
+
+func (r JSON) ContentType() string { return MIMEJSON }
+
+func (r JSON) Render(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) ContentType() string { return MIMEJSON }
+
+func (r IndentedJSON) Render(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(r.Data)
+}
+
+//SecureJSON prefixes JSON arrays so that they can't be hijacked by a <script> tag
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) ContentType() string { return MIMEJSON }
+
+func (r SecureJSON) Render(w io.Writer) error {
+	body, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(body, []byte("[")) && bytes.HasSuffix(body, []byte("]")) {
+		if _, err = io.WriteString(w, r.Prefix); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+var jsonpCallback = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$.]*$`)
+
+//JSONP wraps JSON in a call to Callback, it must be a plain JavaScript identifier
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JSONP) ContentType() string { return MIMEJavaScript }
+
+func (r JSONP) Render(w io.Writer) error {
+	if !jsonpCallback.MatchString(r.Callback) {
+		return fmt.Errorf("gee: invalid JSONP callback %q", r.Callback)
+	}
+	body, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", r.Callback, body)
+	return err
+}
+
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) ContentType() string { return MIMEXML }
+
+func (r XML) Render(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) ContentType() string { return MIMEYAML }
+
+func (r YAML) Render(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(r.Data); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+type MsgPack struct {
+	Data interface{}
+}
+
+func (r MsgPack) ContentType() string { return MIMEMsgPack }
+
+func (r MsgPack) Render(w io.Writer) error {
+	return msgpack.NewEncoder(w).Encode(r.Data)
+}
+
+//ProtoBuf needs Data to be a proto.Message
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) ContentType() string { return MIMEProtoBuf }
+
+func (r ProtoBuf) Render(w io.Writer) error {
+	msg, ok := r.Data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gee: %T is not a proto.Message", r.Data)
+	}
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+type String struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r String) ContentType() string { return MIMEPlain }
+
+func (r String) Render(w io.Writer) error {
+	_, err := fmt.Fprintf(w, r.Format, r.Data...)
+	return err
+}
+
+//Data writes raw bytes, an empty Type leaves Content-Type unset
+type Data struct {
+	Type string
+	Data []byte
+}
+
+func (r Data) ContentType() string { return r.Type }
+
+func (r Data) Render(w io.Writer) error {
+	_, err := w.Write(r.Data)
+	return err
+}
+
+type HTML struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTML) ContentType() string { return MIMEHTML }
+
+func (r HTML) Render(w io.Writer) error {
+	if r.Template == nil {
+		return errors.New("gee: no HTML templates loaded, call LoadHTMLGlob first")
+	}
+	return r.Template.ExecuteTemplate(w, r.Name, r.Data)
+}
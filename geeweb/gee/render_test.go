@@ -0,0 +1,130 @@
+package gee
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type renderUser struct {
+	Name string `json:"name" xml:"name" yaml:"name" msgpack:"name"`
+}
+
+func TestRender(t *testing.T) {
+	r := New()
+	user := renderUser{Name: "geek"}
+	r.GET("/xml", func(c *Context) { c.XML(http.StatusOK, user) })
+	r.GET("/yaml", func(c *Context) { c.YAML(http.StatusOK, user) })
+	r.GET("/indented", func(c *Context) { c.IndentedJSON(http.StatusOK, user) })
+	r.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []string{"a"}) })
+	r.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, user) })
+	r.GET("/broken", func(c *Context) { c.JSON(http.StatusOK, func() {}) })
+
+	cases := []struct {
+		path, contentType, body string
+		code                    int
+	}{
+		{"/xml", MIMEXML, "<renderUser><name>geek</name></renderUser>", 200},
+		{"/yaml", MIMEYAML, "name: geek\n", 200},
+		{"/indented", MIMEJSON, "{\n    \"name\": \"geek\"\n}\n", 200},
+		{"/secure", MIMEJSON, `while(1);["a"]`, 200},
+		{"/jsonp?callback=cb", MIMEJavaScript, `cb({"name":"geek"});`, 200},
+		{"/jsonp?callback=alert(1)", MIMEJSON, "{\"code\":500,\"error\":\"Internal Server Error\"}\n", 500},
+		{"/broken", MIMEJSON, "{\"code\":500,\"error\":\"Internal Server Error\"}\n", 500},
+	}
+	for _, c := range cases {
+		w := doRequest(r, "GET", c.path)
+		if w.Code != c.code || w.Header().Get("Content-Type") != c.contentType {
+			t.Fatalf("%s: got %d %s", c.path, w.Code, w.Header().Get("Content-Type"))
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Fatalf("%s: got body %q", c.path, w.Body.String())
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	r.GET("/user", func(c *Context) { c.Negotiate(http.StatusOK, renderUser{Name: "geek"}) })
+	r.GET("/pb", func(c *Context) { c.Negotiate(http.StatusOK, wrapperspb.String("geek")) })
+
+	cases := map[string]string{
+		"":                                    MIMEJSON,
+		"application/xml":                     MIMEXML,
+		"text/html, application/x-yaml;q=0.9": MIMEYAML,
+		"application/*;q=0.5, application/x-msgpack": MIMEMsgPack,
+		"*/*": MIMEJSON,
+		"*/*, application/json;q=0": MIMEXML,
+	}
+	for accept, expect := range cases {
+		req := httptest.NewRequest("GET", "/user", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("Content-Type"); got != expect {
+			t.Fatalf("Accept %q: got %s, expect %s", accept, got, expect)
+		}
+		if expect == MIMEMsgPack {
+			var user renderUser
+			if err := msgpack.Unmarshal(w.Body.Bytes(), &user); err != nil || user.Name != "geek" {
+				t.Fatalf("msgpack body: %v %+v", err, user)
+			}
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/user", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expect 406, got %d", w.Code)
+	}
+
+	//an offer takes the q of its most specific range, q=0 refuses it
+	for _, tc := range []struct {
+		accept string
+		offers []string
+		expect string
+	}{
+		{"application/json;q=0", []string{MIMEJSON, MIMEXML}, ""},
+		{"text/*;q=0.5, text/xml;q=0.1, application/json;q=0.3", []string{"text/xml", MIMEJSON}, MIMEJSON},
+		{"text/*;q=0.5, text/xml;q=0.1, application/json;q=0.3", []string{"text/xml", MIMEJSON, "text/plain"}, "text/plain"},
+	} {
+		c := &Context{Req: httptest.NewRequest("GET", "/", nil)}
+		c.Req.Header.Set("Accept", tc.accept)
+		if got := c.NegotiateFormat(tc.offers...); got != tc.expect {
+			t.Fatalf("Accept %q offering %v: got %q, expect %q", tc.accept, tc.offers, got, tc.expect)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/pb", nil)
+	req.Header.Set("Accept", MIMEProtoBuf)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var msg wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil || msg.Value != "geek" {
+		t.Fatalf("protobuf body: %v %v", err, msg.Value)
+	}
+}
+
+func TestRenderLargeBody(t *testing.T) {
+	big := strings.Repeat("x", 2*maxPooledBuffer)
+	r := New()
+	r.GET("/string", func(c *Context) { c.String(http.StatusOK, "%s", big) })
+	r.GET("/data", func(c *Context) { c.Data(http.StatusOK, []byte(big)) })
+
+	for _, path := range []string{"/string", "/data"} {
+		if w := doRequest(r, "GET", path); w.Body.Len() != len(big) {
+			t.Fatalf("%s: got %d bytes", path, w.Body.Len())
+		}
+	}
+	if buf := bufferPool.Get().(*bytes.Buffer); buf.Cap() > maxPooledBuffer {
+		t.Fatalf("a %d bytes buffer went back to the pool", buf.Cap())
+	}
+}
@@ -0,0 +1,140 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+//ResponseWriter records what was sent so that middlewares can look at it
+//after c.Next(). The status line is held back until the first Write, Flush
+//or the end of the request, so it can still be changed before that.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.CloseNotifier
+
+	//Status is the status code sent or about to be sent, 200 by default
+	Status() int
+	//Size is the number of body bytes written
+	Size() int
+	//Written reports whether the status line and headers were sent
+	Written() bool
+	//WriteHeaderNow sends the status line and headers if not done yet
+	WriteHeaderNow()
+	//Unwrap returns the original writer, for http.ResponseController
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int
+	written  bool
+	hijacked bool
+	//statusSet is true once WriteHeader chose the status, the error
+	//handler then leaves the response alone
+	statusSet bool
+}
+
+var _ ResponseWriter = &responseWriter{}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = 0
+	w.written = false
+	w.hijacked = false
+	w.statusSet = false
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+		w.statusSet = true
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.written && !w.hijacked {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return n, err
+}
+
+//ReadFrom keeps the sendfile fast path of net/http for io.Copy
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.WriteHeaderNow()
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += int(n)
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//Flush sends the headers and is a no-op when the writer can't flush
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack hands the connection over, nothing is written by gee afterwards
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: the ResponseWriter doesn't support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+//CloseNotify is kept for older code, prefer c.Req.Context().Done().
+//A writer without support gets a channel that never fires.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,82 @@
+package gee
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var status, size int
+	var written bool
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size, written = c.Writer.Status(), c.Writer.Size(), c.Writer.Written()
+	})
+	r.GET("/direct", func(c *Context) {
+		c.Writer.WriteHeader(http.StatusCreated)
+		c.Writer.Write([]byte("hello"))
+	})
+	r.GET("/status", func(c *Context) {
+		c.Status(http.StatusAccepted)
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/flush", func(c *Context) {
+		c.Writer.Flush()
+		c.Writer.WriteHeader(http.StatusTeapot)
+	})
+
+	cases := []struct {
+		path          string
+		status, size  int
+		recorderCode  int
+		writtenByNext bool
+	}{
+		{"/direct", http.StatusCreated, 5, http.StatusCreated, true},
+		{"/status", http.StatusNoContent, 0, http.StatusNoContent, false},
+		{"/flush", http.StatusOK, 0, http.StatusOK, true},
+	}
+	for _, c := range cases {
+		w := doRequest(r, "GET", c.path)
+		if status != c.status || size != c.size || written != c.writtenByNext || w.Code != c.recorderCode {
+			t.Fatalf("%s: got status=%d size=%d written=%v code=%d", c.path, status, size, written, w.Code)
+		}
+	}
+	if w := doRequest(r, "GET", "/flush"); !w.Flushed {
+		t.Fatal("Flush should reach the underlying writer")
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	r := New()
+	r.GET("/hijack", func(c *Context) {
+		conn, rw, err := c.Writer.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nraw ok")
+		rw.Flush()
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	if line != "raw ok" {
+		t.Fatalf("unexpected body %q", line)
+	}
+
+	w := &responseWriter{}
+	w.reset(httptest.NewRecorder())
+	if _, _, err := w.Hijack(); err == nil {
+		t.Fatal("a recorder can't be hijacked")
+	}
+}
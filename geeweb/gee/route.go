@@ -0,0 +1,119 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Route is returned when a route is registered
+type Route struct {
+	Method  string
+	Pattern string //full pattern, group prefixes included
+
+	engine   *Engine
+	handlers []HandlerFunc //full chain, the handler is the last one
+}
+
+// RouteInfo describes a registered route, see Engine.Routes
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Pattern     string `json:"pattern"`
+	Handler     string `json:"handler"`
+	Middlewares int    `json:"middlewares"`
+}
+
+// Routes lists the registered routes in registration order
+func (engine *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(engine.routes))
+	for _, route := range engine.routes {
+		last := len(route.handlers) - 1
+		routes = append(routes, RouteInfo{
+			Method:      route.Method,
+			Pattern:     route.Pattern,
+			Handler:     nameOfFunction(route.handlers[last]),
+			Middlewares: last,
+		})
+	}
+	return routes
+}
+
+func nameOfFunction(f interface{}) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	return runtime.FuncForPC(v.Pointer()).Name()
+}
+
+// Name registers the route under name for URLFor and the url template func
+func (route *Route) Name(name string) *Route {
+	if old, ok := route.engine.namedRoutes[name]; ok && old.Pattern != route.Pattern {
+		panic(fmt.Sprintf("gee: route name %s is already used by %s", name, old.Pattern))
+	}
+	route.engine.namedRoutes[name] = route
+	return route
+}
+
+// URLFor builds the path of a named route from key/value pairs,
+// e.g. URLFor("user.show", "id", 42) gives /users/42 for /users/:id.
+// Pairs that are not params of the pattern are added as query string.
+func (engine *Engine) URLFor(name string, pairs ...interface{}) (string, error) {
+	route, ok := engine.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: no route named %s", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", errors.New("gee: URLFor expects key/value pairs")
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[fmt.Sprint(pairs[i])] = fmt.Sprint(pairs[i+1])
+	}
+
+	var b strings.Builder
+	for _, part := range parsePattern(route.Pattern) {
+		b.WriteString("/")
+		if kindOf(part) == static {
+			b.WriteString(part)
+			continue
+		}
+		key, re, err := parseWild(part)
+		if err != nil {
+			return "", err
+		}
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("gee: route %s needs a value for %s", name, key)
+		}
+		if re != nil && !re.MatchString(value) {
+			return "", fmt.Errorf("gee: value %q for %s doesn't satisfy %s", value, key, part)
+		}
+		delete(values, key)
+		if kindOf(part) == catchAll {
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteString("/")
+	}
+
+	query := url.Values{}
+	for key, value := range values {
+		query.Set(key, value)
+	}
+	if len(query) > 0 {
+		b.WriteString("?")
+		b.WriteString(query.Encode())
+	}
+	return b.String(), nil
+}
@@ -1,19 +1,27 @@
 package gee
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
+//methods registered by RouterGroup.Any
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+	http.MethodConnect, http.MethodTrace,
+}
+
 type router struct {
 	roots map[string]*node
-	handlers map[string]HandlerFunc
+	maxParams int //most wildcards in one pattern, used to size Context.Params
 }
 
 func newRouter()*router{
 	return &router{
-        roots: make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots: make(map[string]*node),
 	}
 }
 
@@ -32,53 +40,143 @@ func parsePattern(pattern string)[]string{
 	return parts
 }
 
-func (r *router)addRoute(method string, pattern string, handler HandlerFunc){
+//cleanPath drops empty segments and the trailing slash, so /a//b/ is /a/b.
+//Paths that are already clean are returned as is without allocating.
+func cleanPath(p string)string{
+	clean := len(p) > 0 && p[0] == '/' && (len(p) == 1 || p[len(p)-1] != '/')
+	for i := 1; clean && i < len(p); i++ {
+		clean = !(p[i] == '/' && p[i-1] == '/')
+	}
+	if clean {
+		return p
+	}
+
+	var b strings.Builder
+	for _, item := range strings.Split(p, "/"){
+		if item != ""{
+			b.WriteString("/")
+			b.WriteString(item)
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
+
+//addRoute registers the chain of pattern, group is the RouterGroup that
+//answers 405 and automatic OPTIONS on the paths of the route
+func (r *router)addRoute(method string, pattern string, handlers []HandlerFunc, group *RouterGroup) error{
 	parts := parsePattern(pattern)
-	key := method + "-" + pattern
 
-	_, ok := r.roots[method]
+	root, ok := r.roots[method]
 	if !ok {
-		r.roots[method] = &node{}
+		root = &node{}
+		r.roots[method] = root
+	}
+	if err := root.insert("/" + strings.Join(parts, "/"), pattern, handlers, group); err != nil {
+		return fmt.Errorf("gee: %s %v", method, err)
+	}
+
+	wild := 0
+	for _, part := range parts{
+		if kindOf(part) != static {
+			wild++
+		}
+	}
+	if wild > r.maxParams {
+		r.maxParams = wild
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	return nil
 }
 
-func (r *router)getRoute(method string, path string)(*node, map[string]string){
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+//find looks up the route for path, captured params are appended to params
+func (r *router)find(method string, path string, params *Params)*node{
 	root, ok := r.roots[method]
 	if !ok{
+		return nil
+	}
+	return root.search(cleanPath(path), params)
+}
+
+func (r *router)getRoute(method string, path string)(*node, Params){
+	params := make(Params, 0, r.maxParams)
+	n := r.find(method, path, &params)
+	if n == nil {
 		return nil, nil
 	}
+	return n, params
+}
 
-	n := root.search(searchParts, 0)
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts{
-			if part[0] == ':'{
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
+//allowed returns the methods that have a route matching path, sorted, and
+//the route of the first of them in that order.
+//HEAD is implied by GET and OPTIONS is always answerable once any method matches.
+func (r *router)allowed(path string)([]string, *node){
+	seen := make(map[string]bool)
+	var first *node
+	firstMethod := ""
+	for method := range r.roots{
+		if n, _ := r.getRoute(method, path); n != nil{
+			seen[method] = true
+			if first == nil || method < firstMethod {
+				first, firstMethod = n, method
 			}
 		}
-		return n, params
 	}
-	return nil, nil
+	if len(seen) == 0{
+		return nil, nil
+	}
+	if seen[http.MethodGet]{
+		seen[http.MethodHead] = true
+	}
+	seen[http.MethodOptions] = true
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen{
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods, first
 }
 
 func (r *router)handle(c *Context){
-	n, params := r.getRoute(c.Method, c.Path)
+	n := r.find(c.Method, c.Path, &c.Params)
+	if n == nil && c.Method == http.MethodHead {
+		//HEAD falls back to GET, net/http discards the body for us
+		n = r.find(http.MethodGet, c.Path, &c.Params)
+	}
+
 	if n != nil {
-		c.Params = params
-		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key])
+		//the chain is shared by all requests of the route, never append to it
+		c.handlers = n.handlers
+	} else if allow, other := r.allowed(c.Path); allow != nil {
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		//the middlewares of the group of the path run, e.g. its CORS
+		group := other.group
+		if group == nil {
+			group = c.engine.RouterGroup
+		}
+		if c.Method == http.MethodOptions {
+			c.handlers = group.allowOptions
+		}else{
+			c.handlers = group.noMethod
+		}
 	}else{
-		c.handlers = append(c.handlers, func(c *Context){
-			c.String(http.StatusNotFound, "404 Not Found:%s\n", c.Path)
-		})
+		//only the engine middlewares run, no group matched
+		c.handlers = c.engine.noRoute
 	}
 	c.Next()
 }
+
+func notFound(c *Context){
+	c.String(http.StatusNotFound, "404 Not Found:%s\n", c.Path)
+}
+
+func methodNotAllowed(c *Context){
+	c.String(http.StatusMethodNotAllowed, "405 Method Not Allowed:%s %s\n", c.Method, c.Path)
+}
+
+//autoOptions answers OPTIONS for paths without an OPTIONS route, Allow is set
+func autoOptions(c *Context){
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package gee
+
+//SessionKey is where the sessions middleware keeps the session of a request
+const SessionKey = "gee/session"
+
+//Session is a map-like store kept across requests, see the sessions package.
+//Changes are saved before the response headers are sent.
+type Session interface {
+	ID() string
+	Get(key string) interface{}
+	Set(key string, value interface{})
+	Delete(key string)
+	//Clear removes every value, an empty session drops its cookie
+	Clear()
+	//AddFlash queues a message for the next request that reads Flashes
+	AddFlash(value interface{})
+	//Flashes returns and removes the queued messages
+	Flashes() []interface{}
+	//RenewID keeps the values under a new ID, call it on login
+	RenewID() error
+	//Save writes the session now instead of with the response headers
+	Save() error
+}
+
+//Session returns the session of the request, it panics when the sessions
+//middleware isn't in the chain
+func (c *Context) Session() Session {
+	return c.MustGet(SessionKey).(Session)
+}
@@ -0,0 +1,120 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidValue = errors.New("sessions: invalid or tampered cookie")
+	ErrExpired      = errors.New("sessions: cookie expired")
+)
+
+//codec signs or encrypts cookie values with the first key and accepts
+//every key, so that a new key can be rolled out before the old one is dropped
+type codec struct {
+	encrypt bool
+	keys    []codecKey
+}
+
+type codecKey struct {
+	hash []byte
+	aead cipher.AEAD
+}
+
+func newCodec(secrets [][]byte, encrypt bool) (*codec, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("sessions: at least one key is needed")
+	}
+	c := &codec{encrypt: encrypt}
+	for _, secret := range secrets {
+		if len(secret) < 16 {
+			return nil, errors.New("sessions: keys must be at least 16 bytes long")
+		}
+		block, err := aes.NewCipher(derive(secret, "gee sessions encryption"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = append(c.keys, codecKey{hash: derive(secret, "gee sessions signature"), aead: aead})
+	}
+	return c, nil
+}
+
+//derive gives independent signing and encryption keys from one secret
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+//encode binds data to the cookie name and the current time
+func (c *codec) encode(name string, data []byte, now time.Time) (string, error) {
+	payload := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(payload, uint64(now.Unix()))
+	payload = append(payload, data...)
+
+	key := c.keys[0]
+	if c.encrypt {
+		nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(payload)+key.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(key.aead.Seal(nonce, nonce, payload, []byte(name))), nil
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, sign(key.hash, name, payload)...)), nil
+}
+
+func sign(key []byte, name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+//decode checks the value against every key, a zero maxAge skips the age check
+func (c *codec) decode(name string, value string, maxAge time.Duration, now time.Time) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+
+	var payload []byte
+	for _, key := range c.keys {
+		if c.encrypt {
+			n := key.aead.NonceSize()
+			if len(raw) < n {
+				return nil, ErrInvalidValue
+			}
+			if plain, err := key.aead.Open(nil, raw[:n], raw[n:], []byte(name)); err == nil {
+				payload = plain
+				break
+			}
+		} else if len(raw) >= 8+sha256.Size {
+			body, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+			if hmac.Equal(mac, sign(key.hash, name, body)) {
+				payload = body
+				break
+			}
+		}
+	}
+	if len(payload) < 8 {
+		return nil, ErrInvalidValue
+	}
+	issued := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if maxAge > 0 && now.Sub(issued) > maxAge {
+		return nil, ErrExpired
+	}
+	return payload[8:], nil
+}
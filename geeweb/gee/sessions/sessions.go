@@ -0,0 +1,316 @@
+//Package sessions keeps gee.Context.Session across requests, either in a
+//signed or encrypted cookie or in a Store with only the signed ID in the
+//cookie.
+//
+//	r.Use(sessions.Sessions(sessions.Config{Keys: [][]byte{key}}))
+//	r.POST("/login", func(c *gee.Context) {
+//		s := c.Session()
+//		s.RenewID()
+//		s.Set("user", name)
+//		s.AddFlash("welcome back")
+//		c.Redirect(http.StatusSeeOther, "/")
+//	})
+//
+//Values are gob encoded, types other than the basic ones must be registered
+//with gob.Register.
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"geeweb/gee"
+)
+
+const flashKey = "_flash"
+
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+	gob.Register(time.Time{})
+}
+
+type Config struct {
+	//Name of the cookie, "gee_session" by default
+	Name string
+	//Keys sign, and with Encrypt encrypt, the cookie. The first one is used
+	//for new cookies, the others are still accepted: prepend a new key to
+	//rotate and drop the old one once its cookies expired.
+	Keys [][]byte
+	//Encrypt hides the values of cookie sessions from the client,
+	//they are only signed otherwise
+	Encrypt bool
+	//Store keeps the values on the server, nil keeps them in the cookie
+	Store Store
+	//MaxAge is how long a session lives after its last change, 24 hours by default
+	MaxAge time.Duration
+
+	//cookie attributes, the cookie is always HttpOnly
+	Path     string //"/" by default
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite //Lax by default
+}
+
+type manager struct {
+	conf  Config
+	codec *codec
+	now   func() time.Time
+}
+
+//Sessions loads the session of every request and stores it under
+//gee.SessionKey. It panics on invalid keys.
+func Sessions(conf Config) gee.HandlerFunc {
+	codec, err := newCodec(conf.Keys, conf.Encrypt)
+	if err != nil {
+		panic(err)
+	}
+	if conf.Name == "" {
+		conf.Name = "gee_session"
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = 24 * time.Hour
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+	if conf.SameSite == 0 {
+		conf.SameSite = http.SameSiteLaxMode
+	}
+	m := &manager{conf: conf, codec: codec, now: time.Now}
+
+	return func(c *gee.Context) {
+		s := m.load(c)
+		c.Set(gee.SessionKey, s)
+		w := &saveWriter{ResponseWriter: c.Writer, s: s}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		//changes made after the body started can't be sent anymore
+		if !c.Writer.Written() {
+			w.save()
+		}
+	}
+}
+
+//record is what gets encoded, in the cookie or in the Store
+type record struct {
+	ID     string
+	Values map[string]interface{}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+//load starts a new session when the cookie is missing, invalid or expired
+func (m *manager) load(c *gee.Context) *session {
+	s := &session{m: m, c: c, values: make(map[string]interface{}), isNew: true}
+	cookie, err := c.Req.Cookie(m.conf.Name)
+	if err != nil {
+		return s
+	}
+	data, err := m.codec.decode(m.conf.Name, cookie.Value, m.conf.MaxAge, m.now())
+	if err != nil {
+		return s
+	}
+	if m.conf.Store != nil {
+		id := string(data)
+		if data, err = m.conf.Store.Get(id); err != nil {
+			c.Error(err)
+			return s
+		}
+		if data == nil {
+			return s
+		}
+	}
+	var rec record
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&rec); err != nil {
+		return s
+	}
+	s.id, s.isNew = rec.ID, false
+	if rec.Values != nil {
+		s.values = rec.Values
+	}
+	return s
+}
+
+type session struct {
+	m      *manager
+	c      *gee.Context
+	id     string
+	values map[string]interface{}
+	isNew  bool
+	dirty  bool
+	oldID  string //deleted from the Store on save after RenewID
+}
+
+var _ gee.Session = &session{}
+
+func (s *session) ID() string {
+	if s.id == "" {
+		s.id, _ = newID()
+	}
+	return s.id
+}
+
+func (s *session) Get(key string) interface{} {
+	return s.values[key]
+}
+
+func (s *session) Set(key string, value interface{}) {
+	s.values[key] = value
+	s.dirty = true
+}
+
+func (s *session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.dirty = true
+	}
+}
+
+func (s *session) Clear() {
+	s.values = make(map[string]interface{})
+	s.dirty = true
+}
+
+func (s *session) AddFlash(value interface{}) {
+	flashes, _ := s.values[flashKey].([]interface{})
+	s.values[flashKey] = append(flashes, value)
+	s.dirty = true
+}
+
+func (s *session) Flashes() []interface{} {
+	flashes, ok := s.values[flashKey].([]interface{})
+	if ok {
+		delete(s.values, flashKey)
+		s.dirty = true
+	}
+	return flashes
+}
+
+func (s *session) RenewID() error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	if s.id != "" && s.oldID == "" && !s.isNew {
+		s.oldID = s.id
+	}
+	s.id = id
+	s.dirty = true
+	return nil
+}
+
+func (s *session) Save() error {
+	store := s.m.conf.Store
+	if s.oldID != "" && store != nil {
+		if err := store.Delete(s.oldID); err != nil {
+			return err
+		}
+	}
+	s.oldID = ""
+
+	if len(s.values) == 0 {
+		//an empty session needs no cookie, drop the one the client has
+		if !s.isNew {
+			if store != nil {
+				if err := store.Delete(s.ID()); err != nil {
+					return err
+				}
+			}
+			s.setCookie("", -1)
+		}
+		s.dirty = false
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record{ID: s.ID(), Values: s.values}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if store != nil {
+		if err := store.Set(s.ID(), data, s.m.conf.MaxAge); err != nil {
+			return err
+		}
+		data = []byte(s.ID())
+	}
+	value, err := s.m.codec.encode(s.m.conf.Name, data, s.m.now())
+	if err != nil {
+		return err
+	}
+	if len(value) > 4000 {
+		return errors.New("sessions: session too large for a cookie, use a Store")
+	}
+	s.setCookie(value, int(s.m.conf.MaxAge/time.Second))
+	s.isNew, s.dirty = false, false
+	return nil
+}
+
+//setCookie replaces a session cookie set earlier in the same response
+func (s *session) setCookie(value string, maxAge int) {
+	header := s.c.Writer.Header()
+	prefix := s.m.conf.Name + "="
+	cookies := header["Set-Cookie"][:0]
+	for _, cookie := range header["Set-Cookie"] {
+		if !strings.HasPrefix(cookie, prefix) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	header["Set-Cookie"] = cookies
+	http.SetCookie(s.c.Writer, &http.Cookie{
+		Name:     s.m.conf.Name,
+		Value:    value,
+		Path:     s.m.conf.Path,
+		Domain:   s.m.conf.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.m.conf.Secure,
+		HttpOnly: true,
+		SameSite: s.m.conf.SameSite,
+	})
+}
+
+//saveWriter saves a changed session right before the headers go out
+type saveWriter struct {
+	gee.ResponseWriter
+	s *session
+}
+
+func (w *saveWriter) save() {
+	if w.s.dirty {
+		if err := w.s.Save(); err != nil {
+			w.s.c.Error(err)
+		}
+	}
+}
+
+func (w *saveWriter) WriteHeaderNow() {
+	w.save()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *saveWriter) Write(data []byte) (int, error) {
+	if !w.ResponseWriter.Written() {
+		w.save()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *saveWriter) Flush() {
+	if !w.ResponseWriter.Written() {
+		w.save()
+	}
+	w.ResponseWriter.Flush()
+}
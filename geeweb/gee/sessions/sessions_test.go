@@ -0,0 +1,194 @@
+package sessions
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"geeweb/gee"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef-old-session-key")
+	newKey = []byte("0123456789abcdef-new-session-key")
+)
+
+func newEngine(conf Config) *gee.Engine {
+	r := gee.New()
+	r.Use(Sessions(conf))
+	r.POST("/login", func(c *gee.Context) {
+		s := c.Session()
+		s.RenewID()
+		s.Set("user", c.Query("name"))
+		s.AddFlash("welcome")
+		c.Redirect(http.StatusSeeOther, "/")
+	})
+	r.GET("/", func(c *gee.Context) {
+		s := c.Session()
+		c.String(http.StatusOK, "%v %v", s.Get("user"), s.Flashes())
+	})
+	r.POST("/logout", func(c *gee.Context) {
+		c.Session().Clear()
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+//client keeps the session cookie between requests
+type client struct {
+	t      *testing.T
+	r      *gee.Engine
+	cookie *http.Cookie
+}
+
+func (cl *client) do(method string, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if cl.cookie != nil {
+		req.AddCookie(cl.cookie)
+	}
+	w := httptest.NewRecorder()
+	cl.r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			cl.cookie = nil
+		} else {
+			cl.cookie = cookie
+		}
+	}
+	return w
+}
+
+func TestCookieSession(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		cl := &client{t: t, r: newEngine(Config{Keys: [][]byte{newKey}, Encrypt: encrypt, Secure: true})}
+		if w := cl.do("GET", "/"); w.Body.String() != "<nil> []" || cl.cookie != nil {
+			t.Fatalf("an untouched session sets no cookie, got %q %v", w.Body.String(), cl.cookie)
+		}
+		w := cl.do("POST", "/login?name=alice")
+		if w.Code != http.StatusSeeOther || cl.cookie == nil || !cl.cookie.HttpOnly || !cl.cookie.Secure ||
+			cl.cookie.SameSite != http.SameSiteLaxMode || cl.cookie.MaxAge != 86400 {
+			t.Fatalf("expected a session cookie, got %d %+v", w.Code, cl.cookie)
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(cl.cookie.Value)
+		if strings.Contains(string(raw), "alice") == encrypt {
+			t.Fatalf("encrypt=%v: unexpected cookie content %q", encrypt, raw)
+		}
+		if w = cl.do("GET", "/"); w.Body.String() != "alice [welcome]" {
+			t.Fatalf("got %q", w.Body.String())
+		}
+		if w = cl.do("GET", "/"); w.Body.String() != "alice []" {
+			t.Fatalf("flashes are read once, got %q", w.Body.String())
+		}
+		cl.do("POST", "/logout")
+		if w = cl.do("GET", "/"); cl.cookie != nil || w.Body.String() != "<nil> []" {
+			t.Fatalf("logout drops the cookie, got %q", w.Body.String())
+		}
+	}
+}
+
+func TestCookieTamperingAndRotation(t *testing.T) {
+	old := &client{t: t, r: newEngine(Config{Keys: [][]byte{oldKey}})}
+	old.do("POST", "/login?name=bob")
+
+	rotated := &client{t: t, r: newEngine(Config{Keys: [][]byte{newKey, oldKey}}), cookie: old.cookie}
+	if w := rotated.do("GET", "/"); w.Body.String() != "bob [welcome]" {
+		t.Fatalf("cookies of the previous key are accepted, got %q", w.Body.String())
+	}
+	dropped := &client{t: t, r: newEngine(Config{Keys: [][]byte{newKey}}), cookie: old.cookie}
+	if w := dropped.do("GET", "/"); w.Body.String() != "<nil> []" {
+		t.Fatalf("cookies of a removed key are refused, got %q", w.Body.String())
+	}
+
+	raw, _ := base64.RawURLEncoding.DecodeString(old.cookie.Value)
+	raw[len(raw)/2] ^= 1
+	old.cookie.Value = base64.RawURLEncoding.EncodeToString(raw)
+	if w := old.do("GET", "/"); w.Body.String() != "<nil> []" {
+		t.Fatalf("tampered cookies start a new session, got %q", w.Body.String())
+	}
+}
+
+func TestStoreSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee-sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": files} {
+		cl := &client{t: t, r: newEngine(Config{Keys: [][]byte{newKey}, Store: store})}
+		cl.do("POST", "/login?name=carol")
+		if cl.cookie == nil || len(cl.cookie.Value) > 200 {
+			t.Fatalf("%s: the cookie only holds the ID, got %+v", name, cl.cookie)
+		}
+		if w := cl.do("GET", "/"); w.Body.String() != "carol [welcome]" {
+			t.Fatalf("%s: got %q", name, w.Body.String())
+		}
+
+		//login again renews the ID, the old one is gone from the store
+		before := cl.cookie
+		cl.do("POST", "/login?name=carol")
+		replay := &client{t: t, r: cl.r, cookie: before}
+		if w := replay.do("GET", "/"); w.Body.String() != "<nil> []" {
+			t.Fatalf("%s: the old ID must not work after RenewID, got %q", name, w.Body.String())
+		}
+		if w := cl.do("GET", "/"); w.Body.String() != "carol [welcome]" {
+			t.Fatalf("%s: got %q", name, w.Body.String())
+		}
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee-sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, _ := NewFileStore(dir)
+	memory := NewMemoryStore()
+	now := time.Now()
+	files.now = func() time.Time { return now }
+	memory.now = files.now
+
+	id := "abcdefghijklmnopqrstuvwxyz"
+	for name, store := range map[string]Store{"memory": memory, "file": files} {
+		if err := store.Set(id, []byte("data"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := store.Get(id); err != nil || string(data) != "data" {
+			t.Fatalf("%s: got %q %v", name, data, err)
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	for name, store := range map[string]Store{"memory": memory, "file": files} {
+		if data, err := store.Get(id); err != nil || data != nil {
+			t.Fatalf("%s: expired sessions are gone, got %q %v", name, data, err)
+		}
+	}
+	if _, err := files.Get("../../etc/passwd"); err == nil {
+		t.Fatal("IDs can't be paths")
+	}
+}
+
+type failingStore struct{ Store }
+
+func (failingStore) Get(id string) ([]byte, error) { return nil, errors.New("store down") }
+
+func TestStoreErrorKeepsTheResponse(t *testing.T) {
+	store := failingStore{NewMemoryStore()}
+	cl := &client{t: t, r: newEngine(Config{Keys: [][]byte{newKey}, Store: store})}
+	cl.do("POST", "/login?name=dave")
+	//loading fails now, the redirect of the handler still stands
+	if w := cl.do("POST", "/login?name=dave"); w.Code != http.StatusSeeOther {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+package sessions
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+//Store keeps session data on the server, the cookie then only holds the
+//signed session ID. Get returns nil data for unknown or expired IDs.
+type Store interface {
+	Get(id string) ([]byte, error)
+	Set(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+//MemoryStore keeps sessions in process, they are lost on restart
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (s *MemoryStore) Get(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok || s.now().After(e.expiresAt) {
+		return nil, nil
+	}
+	return e.data, nil
+}
+
+func (s *MemoryStore) Set(id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for key, e := range s.entries {
+			if now.After(e.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+	}
+	s.entries[id] = memoryEntry{data: data, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.entries, id)
+	s.mu.Unlock()
+	return nil
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+//FileStore keeps one file per session in a directory, expired files are
+//removed when they are read
+type FileStore struct {
+	dir string
+	now func() time.Time
+}
+
+//NewFileStore creates dir if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, now: time.Now}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", errors.New("sessions: invalid session ID")
+	}
+	return filepath.Join(s.dir, "session_"+id), nil
+}
+
+func (s *FileStore) Get(id string) ([]byte, error) {
+	name, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 8 {
+		return nil, errors.New("sessions: corrupted session file " + name)
+	}
+	if expiresAt := time.Unix(0, int64(binary.BigEndian.Uint64(content))); s.now().After(expiresAt) {
+		os.Remove(name)
+		return nil, nil
+	}
+	return content[8:], nil
+}
+
+//Set writes a temporary file first so that readers never see half a session
+func (s *FileStore) Set(id string, data []byte, ttl time.Duration) error {
+	name, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	content := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(content, uint64(s.now().Add(ttl).UnixNano()))
+	content = append(content, data...)
+
+	tmp, err := ioutil.TempFile(s.dir, "tmp_")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *FileStore) Delete(id string) error {
+	name, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
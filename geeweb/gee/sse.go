@@ -0,0 +1,91 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const MIMEEventStream = "text/event-stream"
+
+//SSEvent is one Server-Sent Event, Data is written as is when it is a
+//string or []byte and as JSON otherwise
+type SSEvent struct {
+	Event string
+	ID    string
+	Retry uint //milliseconds, 0 leaves it out
+	Data  interface{}
+}
+
+func (r SSEvent) ContentType() string { return MIMEEventStream }
+
+func (r SSEvent) Render(w io.Writer) error {
+	var b strings.Builder
+	if r.ID != "" {
+		b.WriteString("id: " + sseEscape(r.ID) + "\n")
+	}
+	if r.Event != "" {
+		b.WriteString("event: " + sseEscape(r.Event) + "\n")
+	}
+	if r.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", r.Retry)
+	}
+
+	var data string
+	switch v := r.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		body, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(body)
+	}
+	//every line of a multi-line payload needs its own data field
+	for _, line := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//sseEscape keeps a field on one line
+func sseEscape(s string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(s)
+}
+
+//SSEvent writes an event named name and flushes it to the client
+func (c *Context) SSEvent(name string, data interface{}) {
+	if !c.Writer.Written() {
+		c.SetHeader("Cache-Control", "no-cache")
+		c.SetHeader("Connection", "keep-alive")
+	}
+	c.Render(http.StatusOK, SSEvent{Event: name, Data: data})
+	c.Writer.Flush()
+}
+
+//Stream calls step until it returns false or the client goes away,
+//flushing after every call. It reports whether the client went away.
+//step should itself select on c.Done() when it blocks waiting for data.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
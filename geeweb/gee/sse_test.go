@@ -0,0 +1,85 @@
+package gee
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEvent(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Context) {
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			i++
+			c.SSEvent("progress", H{"step": i})
+			return i < 3
+		})
+		c.SSEvent("", "done\nbye")
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != MIMEEventStream || res.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("unexpected headers %v", res.Header)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	var expect strings.Builder
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(&expect, "event: progress\ndata: {\"step\":%d}\n\n", i)
+	}
+	expect.WriteString("data: done\ndata: bye\n\n")
+	if string(body) != expect.String() {
+		t.Fatalf("got %q", body)
+	}
+}
+
+func TestStreamClientGone(t *testing.T) {
+	gone := make(chan bool, 1)
+	r := New()
+	r.GET("/stream", func(c *Context) {
+		gone <- c.Stream(func(w io.Writer) bool {
+			io.WriteString(w, "tick\n")
+			select {
+			case <-c.Done():
+			case <-time.After(10 * time.Millisecond):
+			}
+			return true
+		})
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", server.URL+"/stream", nil)
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(res.Body).ReadString('\n'); line != "tick\n" {
+		t.Fatalf("unexpected first chunk %q", line)
+	}
+	cancel()
+	res.Body.Close()
+
+	select {
+	case clientGone := <-gone:
+		if !clientGone {
+			t.Fatal("Stream should report the client went away")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stream didn't stop after the client went away")
+	}
+}
@@ -0,0 +1,225 @@
+package gee
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//StaticConfig tunes how StaticFSWithConfig serves files
+type StaticConfig struct {
+	//Index is served for directories, e.g. "index.html", "" disables it
+	Index string
+	//Browse lists directories that have no Index
+	Browse bool
+	//SPA serves the root Index for missing paths without a file extension,
+	//so that the client side router can handle them
+	SPA bool
+	//CacheControl is set on files, e.g. "public, max-age=31536000, immutable"
+	//for a fingerprinted bundle. "" leaves the header unset.
+	CacheControl string
+	//IndexCacheControl is set when Index is served, "no-cache" by default so
+	//that a new deploy is picked up
+	IndexCacheControl string
+}
+
+//Static serves the directory root under relativePath
+func (group *RouterGroup) Static(relativePath string, root string) *Route {
+	return group.StaticFS(relativePath, http.Dir(root))
+}
+
+//StaticFS serves fs under relativePath with index.html for directories and
+//no listing. An embedded bundle goes through http.FS:
+//
+//	sub, _ := fs.Sub(dist, "dist")
+//	r.StaticFS("/", http.FS(sub))
+func (group *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) *Route {
+	return group.StaticFSWithConfig(relativePath, fs, StaticConfig{Index: "index.html"})
+}
+
+//StaticFSWithConfig serves fs under relativePath, it answers GET and HEAD
+//with Range, If-Modified-Since and If-None-Match support
+func (group *RouterGroup) StaticFSWithConfig(relativePath string, fs http.FileSystem, conf StaticConfig) *Route {
+	if conf.IndexCacheControl == "" {
+		conf.IndexCacheControl = "no-cache"
+	}
+	s := &staticServer{fs: fs, conf: conf}
+	handler := func(c *Context) {
+		s.serve(c, "/"+c.Param("filepath"))
+	}
+	//the catch-all doesn't match the directory itself
+	route := group.GET(path.Join(relativePath, "/*filepath"), handler)
+	group.GET(relativePath, handler)
+	return route
+}
+
+//StaticFile serves the file at file on relativePath
+func (group *RouterGroup) StaticFile(relativePath string, file string) *Route {
+	return group.StaticFileFS(relativePath, filepath.Base(file), http.Dir(filepath.Dir(file)))
+}
+
+//StaticFileFS serves the file name of fs on relativePath
+func (group *RouterGroup) StaticFileFS(relativePath string, name string, fs http.FileSystem) *Route {
+	if strings.Contains(relativePath, ":") || strings.Contains(relativePath, "*") {
+		panic("gee: URL parameters can not be used when serving a static file")
+	}
+	s := &staticServer{fs: fs}
+	return group.GET(relativePath, func(c *Context) {
+		s.serve(c, path.Clean("/"+name))
+	})
+}
+
+type staticServer struct {
+	fs   http.FileSystem
+	conf StaticConfig
+	//etags of files without a modification time, embedded files never change
+	etags sync.Map
+}
+
+func (s *staticServer) serve(c *Context, name string) {
+	name = path.Clean(name)
+	f, err := s.fs.Open(name)
+	if err != nil {
+		s.notFound(c, name)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		s.notFound(c, name)
+		return
+	}
+
+	if !stat.IsDir() {
+		if s.conf.CacheControl != "" {
+			c.SetHeader("Cache-Control", s.conf.CacheControl)
+		}
+		s.serveContent(c, name, f, stat)
+		return
+	}
+
+	//relative links of a directory only work with the trailing slash. The
+	//target is relative, a raw //host path must not become Location
+	if urlPath := c.Req.URL.Path; !strings.HasSuffix(urlPath, "/") {
+		target := path.Base(urlPath) + "/"
+		if c.Req.URL.RawQuery != "" {
+			target += "?" + c.Req.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, target)
+		return
+	}
+	if s.conf.Index != "" && s.serveIndex(c, path.Join(name, s.conf.Index)) {
+		return
+	}
+	if s.conf.Browse {
+		s.list(c, f)
+		return
+	}
+	s.notFound(c, name)
+}
+
+func (s *staticServer) serveIndex(c *Context, name string) bool {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		return false
+	}
+	c.SetHeader("Cache-Control", s.conf.IndexCacheControl)
+	s.serveContent(c, name, f, stat)
+	return true
+}
+
+func (s *staticServer) notFound(c *Context, name string) {
+	if s.conf.SPA && s.conf.Index != "" && path.Ext(name) == "" && s.serveIndex(c, "/"+s.conf.Index) {
+		return
+	}
+	c.Fail(http.StatusNotFound, "file not found")
+}
+
+//serveContent adds an ETag, http.ServeContent answers the conditional and
+//range requests with it
+func (s *staticServer) serveContent(c *Context, name string, f http.File, stat os.FileInfo) {
+	modTime := stat.ModTime()
+	if modTime.IsZero() || modTime.Equal(time.Unix(0, 0)) {
+		etag, err := s.contentETag(name, f)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err)
+			return
+		}
+		c.SetHeader("ETag", etag)
+	} else {
+		c.SetHeader("ETag", fmt.Sprintf(`W/"%x-%x"`, stat.Size(), modTime.UnixNano()))
+	}
+	http.ServeContent(c.Writer, c.Req, stat.Name(), modTime, f)
+}
+
+func (s *staticServer) contentETag(name string, f http.File) (string, error) {
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<ul>
+{{- range .Entries}}
+<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+func (s *staticServer) list(c *Context, dir http.File) {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	type entry struct {
+		Name string
+		URL  string
+	}
+	entries := make([]entry, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, entry{Name: name, URL: (&url.URL{Path: name}).String()})
+	}
+	c.Render(http.StatusOK, HTML{Template: listingTemplate, Name: "listing", Data: H{
+		"Path":    c.Req.URL.Path,
+		"Entries": entries,
+	}})
+}
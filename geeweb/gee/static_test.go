@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//embedFS drops modification times the way embed.FS does
+type embedFS struct{ http.FileSystem }
+
+type embedFile struct{ http.File }
+
+type embedInfo struct{ os.FileInfo }
+
+func (fs embedFS) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return embedFile{f}, nil
+}
+
+func (f embedFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return embedInfo{info}, nil
+}
+
+func (embedInfo) ModTime() time.Time { return time.Time{} }
+
+func newStaticDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gee-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.html":      "<h1>app</h1>",
+		"assets/app.js":   "console.log(1)",
+		"assets/app.css":  "body{}",
+		"docs/readme.txt": "read me",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStaticFS(t *testing.T) {
+	dir := newStaticDir(t)
+	defer os.RemoveAll(dir)
+	r := New()
+	r.StaticFSWithConfig("/", embedFS{http.Dir(dir)}, StaticConfig{
+		Index:        "index.html",
+		SPA:          true,
+		CacheControl: "public, max-age=31536000",
+	})
+	r.GET("/api/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+
+	w := doRequest(r, "GET", "/assets/app.js")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "console.log(1)" || etag == "" ||
+		w.Header().Get("Cache-Control") != "public, max-age=31536000" || w.Header().Get("Last-Modified") != "" {
+		t.Fatalf("got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	req := httptest.NewRequest("GET", "/assets/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/", "/settings/profile"} {
+		w = doRequest(r, "GET", path)
+		if w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" || w.Header().Get("Cache-Control") != "no-cache" {
+			t.Fatalf("%s should serve the index, got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+	if w = doRequest(r, "GET", "/api/ping"); w.Body.String() != "pong" {
+		t.Fatalf("routes should win over static files, got %q", w.Body.String())
+	}
+	if w = doRequest(r, "GET", "/assets/missing.js"); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "file not found") {
+		t.Fatalf("missing files with an extension get a 404, got %d %q", w.Code, w.Body.String())
+	}
+	if w = doRequest(r, "GET", "/../../etc/passwd"); w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" {
+		t.Fatalf("paths can't leave the root, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticListing(t *testing.T) {
+	dir := newStaticDir(t)
+	defer os.RemoveAll(dir)
+	r := New()
+	r.Static("/plain", dir)
+	r.StaticFSWithConfig("/browse", http.Dir(dir), StaticConfig{Browse: true})
+	r.StaticFile("/favicon.txt", filepath.Join(dir, "docs", "readme.txt"))
+
+	w := doRequest(r, "GET", "/plain/docs/readme.txt")
+	if w.Code != http.StatusOK || w.Body.String() != "read me" || w.Header().Get("Last-Modified") == "" || w.Header().Get("ETag") == "" {
+		t.Fatalf("got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w = doRequest(r, "GET", "/plain/"); w.Body.String() != "<h1>app</h1>" {
+		t.Fatalf("Static serves index.html, got %q", w.Body.String())
+	}
+	if w = doRequest(r, "GET", "/plain/docs/"); w.Code != http.StatusNotFound {
+		t.Fatalf("listing is off by default, got %d", w.Code)
+	}
+
+	if w = doRequest(r, "GET", "/browse/assets"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/browse/assets/" {
+		t.Fatalf("directories redirect to the trailing slash, got %d %v", w.Code, w.Header())
+	}
+	if w = doRequest(r, "GET", "//browse/assets"); w.Code != http.StatusMovedPermanently || strings.HasPrefix(w.Header().Get("Location"), "//") {
+		t.Fatalf("the redirect must stay on this host, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = doRequest(r, "GET", "/browse/assets/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="app.css">app.css</a>`) ||
+		!strings.Contains(w.Body.String(), `<a href="app.js">app.js</a>`) {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+
+	if w = doRequest(r, "GET", "/favicon.txt"); w.Code != http.StatusOK || w.Body.String() != "read me" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+}
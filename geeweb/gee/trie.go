@@ -1,72 +1,251 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
+//node kinds, lower is matched first
+const (
+	static = iota
+	param
+	catchAll
+)
+
+//node is a node of the compressed radix tree.
+//static nodes hold a byte prefix that may span several segments,
+//wildcard nodes hold exactly one part such as :lang or *filepath.
 type node struct {
-	pattern string //待匹配的路由，例如 /p/:lang
-	part    string //路由中的一部分 例如 :lang
-	children []*node //子节点
-	isWild  bool   //是否精确匹配  part含有:或者*时为true
+	pattern string //待匹配的路由，例如 /p/:lang, 只有路由终点才有
+	part    string //static 节点是公共前缀, wildcard 节点是 :lang, :id{int} 或 *filepath
+	kind    int
+	key     string         //wildcard 节点的参数名
+	re      *regexp.Regexp //:param 的约束, nil 表示任意值
+
+	indices  string  //首字节索引, 与 children 一一对应
+	children []*node //static 子节点
+	paramChildren []*node //有约束的按注册顺序在前, 无约束的在最后
+	catchAllChild *node
+
+	handlers []HandlerFunc //middleware 链, 最后一个是路由的 handler
+	group    *RouterGroup  //注册路由的 group, 用于 405 和自动 OPTIONS
 }
 
-//第一个匹配成功的节点
-func (n *node)matchChild(part string) *node{
-	for _, child := range n.children{
-		if child.part == part || child.isWild{
-			return child
+//paramTypes are the shorthands accepted as :name{type}
+var paramTypes = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"slug":  `[a-z0-9]+(?:-[a-z0-9]+)*`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+//parseWild splits :id{[0-9]+} into its key and compiled constraint,
+//the constraint has to match the whole segment
+func parseWild(part string)(key string, re *regexp.Regexp, err error){
+	i := strings.IndexByte(part, '{')
+	if i < 0 {
+		return part[1:], nil, nil
+	}
+	if part[len(part)-1] != '}' {
+		return "", nil, fmt.Errorf("wildcard %s has an unterminated constraint", part)
+	}
+	if part[0] == '*' {
+		return "", nil, fmt.Errorf("wildcard %s: constraints are only supported on :param", part)
+	}
+	expr := part[i+1:len(part)-1]
+	if typ, ok := paramTypes[expr]; ok {
+		expr = typ
+	}
+	re, err = regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return "", nil, fmt.Errorf("wildcard %s has an invalid constraint: %v", part, err)
+	}
+	return part[1:i], re, nil
+}
+
+func constraintOf(n *node)string{
+	if n.re == nil {
+		return ""
+	}
+	return n.re.String()
+}
+
+func kindOf(part string)int{
+	switch part[0] {
+	case ':':
+		return param
+	case '*':
+		return catchAll
+	}
+	return static
+}
+
+//staticPrefix returns the leading static bytes of path,
+//a wildcard only starts right after a '/'
+func staticPrefix(path string)string{
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*') {
+			return path[:i]
 		}
 	}
-	return nil
+	return path
 }
 
-//所有匹配成功的节点
-func (n *node)matchChildren(part string)[]*node{
-	nodes := make([]*node, 0)
+func commonPrefix(a, b string)int{
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
 
-	for _, child := range n.children{
-		if child.part == part || child.isWild{
-			nodes = append(nodes, child)
+func (n *node)staticChild(c byte)*node{
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == c {
+			return n.children[i]
 		}
 	}
+	return nil
+}
 
-	return nodes
+//split keeps n.part[:i] in n and moves the rest into a new child
+func (n *node)split(i int){
+	child := *n
+	child.part = n.part[i:]
+	*n = node{
+		part: n.part[:i],
+		kind: static,
+		indices: string(child.part[0]),
+		children: []*node{&child},
+	}
 }
 
-func (n *node) insert(pattern string, parts []string, height int){
-	if len(parts) == height {
-		n.pattern = pattern
+//addParamChild keeps the unconstrained param, if any, last
+func (n *node)addParamChild(child *node){
+	last := len(n.paramChildren) - 1
+	if child.re != nil && last >= 0 && n.paramChildren[last].re == nil {
+		n.paramChildren = append(n.paramChildren[:last], child, n.paramChildren[last])
 		return
 	}
+	n.paramChildren = append(n.paramChildren, child)
+}
 
-	part := parts[height]
-	child := n.matchChild(part)
-	if child == nil {
-		child = &node{
-			part: part,
-			isWild: part[0] == ':' || part[0] == '*',
+//insert adds the normalized path below n, n.part is already consumed
+func (n *node)insert(path string, pattern string, handlers []HandlerFunc, group *RouterGroup) error{
+	if path == "" {
+		if n.pattern != "" {
+			return fmt.Errorf("route %s conflicts with existing route %s", pattern, n.pattern)
+		}
+		n.pattern = pattern
+		n.handlers = handlers
+		n.group = group
+		return nil
+	}
+
+	switch kindOf(path) {
+	case param:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
 		}
+		part := path[:end]
+		var child *node
+		for _, c := range n.paramChildren {
+			if c.part == part {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			key, re, err := parseWild(part)
+			if err != nil {
+				return err
+			}
+			child = &node{part: part, kind: param, key: key, re: re}
+			//two params at one position must be told apart by their constraints
+			for _, c := range n.paramChildren {
+				if constraintOf(c) == constraintOf(child) {
+					return fmt.Errorf("wildcard %s in route %s conflicts with existing wildcard %s",
+						part, pattern, c.part)
+				}
+			}
+			n.addParamChild(child)
+		}
+		return child.insert(path[end:], pattern, handlers, group)
+	case catchAll:
+		if n.catchAllChild != nil {
+			if n.catchAllChild.part == path {
+				return fmt.Errorf("route %s conflicts with existing route %s", pattern, n.catchAllChild.pattern)
+			}
+			return fmt.Errorf("wildcard %s in route %s conflicts with existing wildcard %s",
+				path, pattern, n.catchAllChild.part)
+		}
+		key, _, err := parseWild(path)
+		if err != nil {
+			return err
+		}
+		n.catchAllChild = &node{part: path, kind: catchAll, key: key, pattern: pattern, handlers: handlers, group: group}
+		return nil
+	}
+
+	prefix := staticPrefix(path)
+	child := n.staticChild(prefix[0])
+	if child == nil {
+		child = &node{part: prefix, kind: static}
+		n.indices += string(prefix[0])
 		n.children = append(n.children, child)
+		return child.insert(path[len(prefix):], pattern, handlers, group)
+	}
+
+	i := commonPrefix(child.part, prefix)
+	if i < len(child.part) {
+		child.split(i)
 	}
-	child.insert(pattern, parts, height + 1)
+	return child.insert(path[i:], pattern, handlers, group)
 }
 
-func (n *node)search(parts []string, height int)*node{
-	if len(parts) == height || strings.HasPrefix(n.part, "*"){
-		if n.pattern == ""{
+//search matches path below n, static children are tried first,
+//then the :param children whose constraint holds, then the *catchall child.
+//Captured values are appended to params and removed again on backtrack.
+func (n *node)search(path string, params *Params)*node{
+	if path == "" {
+		if n.pattern == "" {
 			return nil
 		}
 		return n
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
-
-	for _, child := range children{
-		result := child.search(parts, height + 1)
-		if result != nil {
+	if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.part) {
+		if result := child.search(path[len(child.part):], params); result != nil {
 			return result
 		}
 	}
 
+	if len(n.paramChildren) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		for _, child := range n.paramChildren {
+			if end == 0 || (child.re != nil && !child.re.MatchString(path[:end])) {
+				continue
+			}
+			*params = append(*params, Param{Key: child.key, Value: path[:end]})
+			if result := child.search(path[end:], params); result != nil {
+				return result
+			}
+			*params = (*params)[:len(*params)-1]
+		}
+	}
+
+	if child := n.catchAllChild; child != nil {
+		if child.key != "" {
+			*params = append(*params, Param{Key: child.key, Value: path})
+		}
+		return child
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,184 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//legacyNode is the segment trie used before the radix tree, kept here as a baseline
+type legacyNode struct {
+	pattern  string
+	part     string
+	children []*legacyNode
+	isWild   bool
+}
+
+func (n *legacyNode) matchChild(part string) *legacyNode {
+	for _, child := range n.children {
+		if child.part == part {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *legacyNode) matchChildren(part string) []*legacyNode {
+	nodes := make([]*legacyNode, 0)
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+func (n *legacyNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+	part := parts[height]
+	child := n.matchChild(part)
+	if child == nil {
+		child = &legacyNode{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		n.children = append(n.children, child)
+	}
+	child.insert(pattern, parts, height+1)
+}
+
+func (n *legacyNode) search(parts []string, height int) *legacyNode {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	for _, child := range n.matchChildren(parts[height]) {
+		if result := child.search(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+type legacyRouter struct {
+	roots    map[string]*legacyNode
+	handlers map[string]HandlerFunc
+}
+
+func (r *legacyRouter) addRoute(method string, pattern string, handler HandlerFunc) {
+	if _, ok := r.roots[method]; !ok {
+		r.roots[method] = &legacyNode{}
+	}
+	r.roots[method].insert(pattern, parsePattern(pattern), 0)
+	r.handlers[method+"-"+pattern] = handler
+}
+
+func (r *legacyRouter) handler(method string, path string) (HandlerFunc, map[string]string) {
+	searchParts := parsePattern(path)
+	params := make(map[string]string)
+	root, ok := r.roots[method]
+	if !ok {
+		return nil, nil
+	}
+	n := root.search(searchParts, 0)
+	if n == nil {
+		return nil, nil
+	}
+	for index, part := range parsePattern(n.pattern) {
+		if part[0] == ':' {
+			params[part[1:]] = searchParts[index]
+		}
+		if part[0] == '*' && len(part) > 1 {
+			params[part[1:]] = strings.Join(searchParts[index:], "/")
+			break
+		}
+	}
+	return r.handlers[method+"-"+n.pattern], params
+}
+
+//benchRoutes looks like a gateway with many resources, each having a few routes
+func benchRoutes() []string {
+	routes := make([]string, 0)
+	for i := 0; i < 500; i++ {
+		base := fmt.Sprintf("/api/v1/resource%d", i)
+		routes = append(routes,
+			base,
+			base+"/:id",
+			base+"/:id/items",
+			base+"/:id/items/:item",
+			base+"/search/all",
+		)
+	}
+	return append(routes, "/static/*filepath")
+}
+
+var benchPaths = []string{
+	"/api/v1/resource499/search/all",
+	"/api/v1/resource250/42/items/7",
+	"/static/css/geektutu.css",
+}
+
+func BenchmarkRadixRouter(b *testing.B) {
+	r := newRouter()
+	for _, route := range benchRoutes() {
+		r.addRoute("GET", route, []HandlerFunc{func(*Context) {}}, nil)
+	}
+	for _, path := range benchPaths {
+		b.Run(path, func(b *testing.B) {
+			params := make(Params, 0, r.maxParams)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				params = params[:0]
+				if r.find("GET", path, &params) == nil {
+					b.Fatalf("%s not found", path)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLegacyRouter(b *testing.B) {
+	r := &legacyRouter{roots: make(map[string]*legacyNode), handlers: make(map[string]HandlerFunc)}
+	for _, route := range benchRoutes() {
+		r.addRoute("GET", route, func(*Context) {})
+	}
+	for _, path := range benchPaths {
+		b.Run(path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if h, _ := r.handler("GET", path); h == nil {
+					b.Fatalf("%s not found", path)
+				}
+			}
+		})
+	}
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	r := New()
+	for _, route := range benchRoutes() {
+		r.GET(route, func(*Context) {})
+	}
+	w := &discardWriter{header: make(http.Header)}
+	for _, path := range benchPaths {
+		req := httptest.NewRequest("GET", path, nil)
+		b.Run(path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.ServeHTTP(w, req)
+			}
+		})
+	}
+}
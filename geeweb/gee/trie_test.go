@@ -8,11 +8,11 @@ import (
 
 func newTestRouter() *router{
 	r := newRouter()
-	r.addRoute("GET", "/", nil)
-	r.addRoute("GET", "/hello/:name", nil)
-	r.addRoute("GET", "/hello/b/c", nil)
-	r.addRoute("GET", "/hi/:name", nil)
-	r.addRoute("GET", "/assets/*filepath", nil)
+	r.addRoute("GET", "/", nil, nil)
+	r.addRoute("GET", "/hello/:name", nil, nil)
+	r.addRoute("GET", "/hello/b/c", nil, nil)
+	r.addRoute("GET", "/hi/:name", nil, nil)
+	r.addRoute("GET", "/assets/*filepath", nil, nil)
 	return r
 }
 
@@ -38,10 +38,149 @@ func TestGetRoute(t *testing.T){
 
 	fmt.Println(ps)
 
-	n, ps = r.getRoute("GET", "/hello/zzz/a")
+	n, ps = r.getRoute("GET", "/hello/zzz")
 	if n == nil {
 		t.Fatal("nil shouldn't be returned")
 	}
 
-	fmt.Println(ps)
+	if n.pattern != "/hello/:name" || ps.ByName("name") != "zzz" {
+		t.Fatal("should match /hello/:name")
+	}
+
+	if n, _ = r.getRoute("GET", "/hello/zzz/a"); n != nil {
+		t.Fatal("/hello/zzz/a shouldn't match any route")
+	}
+}
+
+func TestRouteConflict(t *testing.T){
+	conflicts := [][2]string{
+		{"/hello/:name", "/hello/:id"},
+		{"/assets/*filepath", "/assets/*path"},
+		{"/hello/:name", "/hello/:name/"},
+		{"/hi/b", "/hi/b"},
+	}
+	for _, c := range conflicts {
+		r := newRouter()
+		if err := r.addRoute("GET", c[0], nil, nil); err != nil {
+			t.Fatalf("unexpected error for %s: %v", c[0], err)
+		}
+		if err := r.addRoute("GET", c[1], nil, nil); err == nil {
+			t.Fatalf("%s should conflict with %s", c[1], c[0])
+		}
+		if err := r.addRoute("POST", c[1], nil, nil); err != nil {
+			t.Fatalf("%s shouldn't conflict across methods: %v", c[1], err)
+		}
+	}
+}
+
+func TestRoutePriority(t *testing.T){
+	r := newRouter()
+	//register in reverse priority order, result must not depend on it
+	r.addRoute("GET", "/files/*filepath", nil, nil)
+	r.addRoute("GET", "/files/:name", nil, nil)
+	r.addRoute("GET", "/files/new", nil, nil)
+	r.addRoute("GET", "/files/:name/raw", nil, nil)
+
+	cases := map[string]string{
+		"/files/new":       "/files/new",
+		"/files/a.txt":     "/files/:name",
+		"/files/new/raw":   "/files/:name/raw",
+		"/files/a/b/c":     "/files/*filepath",
+		"/files/new/other": "/files/*filepath",
+	}
+	for path, pattern := range cases {
+		n, _ := r.getRoute("GET", path)
+		if n == nil || n.pattern != pattern {
+			t.Fatalf("%s should match %s", path, pattern)
+		}
+	}
+}
+
+func TestRadixSplit(t *testing.T){
+	r := newRouter()
+	routes := []string{"/help", "/hello/:name", "/hel", "/h/:a/:b", "/", "/hello/:name/*rest"}
+	for _, route := range routes {
+		if err := r.addRoute("GET", route, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct{
+		path, pattern, params string
+	}{
+		{"/", "/", "[]"},
+		{"/hel", "/hel", "[]"},
+		{"/help", "/help", "[]"},
+		{"//hello/geek/", "/hello/:name", "[{name geek}]"},
+		{"/hello/geek/a/b", "/hello/:name/*rest", "[{name geek} {rest a/b}]"},
+		{"/h/1/2", "/h/:a/:b", "[{a 1} {b 2}]"},
+	}
+	for _, c := range cases {
+		n, ps := r.getRoute("GET", c.path)
+		if n == nil || n.pattern != c.pattern || fmt.Sprint(ps) != c.params {
+			t.Fatalf("%s should match %s with %s, got %v %v", c.path, c.pattern, c.params, n, ps)
+		}
+	}
+	for _, path := range []string{"/he", "/hello", "/h/1"} {
+		if n, _ := r.getRoute("GET", path); n != nil {
+			t.Fatalf("%s shouldn't match %s", path, n.pattern)
+		}
+	}
+}
+
+func TestFindAllocs(t *testing.T){
+	r := newTestRouter()
+	params := make(Params, 0, r.maxParams)
+	for _, path := range []string{"/hello/b/c", "/hello/geek", "/assets/css/a.css"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			r.find("GET", path, &params)
+		})
+		if allocs != 0 {
+			t.Fatalf("find %s allocated %v times", path, allocs)
+		}
+	}
+}
+
+func TestParamConstraint(t *testing.T){
+	r := newRouter()
+	routes := []string{
+		"/users/:name",
+		"/users/:id{int}",
+		"/users/:uid{uuid}",
+		"/files/:name{.+\\.png}",
+		"/posts/:slug{slug}/:n{[0-9]{2}}",
+	}
+	for _, route := range routes {
+		if err := r.addRoute("GET", route, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct{
+		path, pattern, params string
+	}{
+		{"/users/42", "/users/:id{int}", "[{id 42}]"},
+		{"/users/3f2504e0-4f89-11d3-9a0c-0305e82c3301", "/users/:uid{uuid}", "[{uid 3f2504e0-4f89-11d3-9a0c-0305e82c3301}]"},
+		{"/users/geek", "/users/:name", "[{name geek}]"},
+		{"/files/a.png", "/files/:name{.+\\.png}", "[{name a.png}]"},
+		{"/posts/hello-gee/07", "/posts/:slug{slug}/:n{[0-9]{2}}", "[{slug hello-gee} {n 07}]"},
+	}
+	for _, c := range cases {
+		n, ps := r.getRoute("GET", c.path)
+		if n == nil || n.pattern != c.pattern || fmt.Sprint(ps) != c.params {
+			t.Fatalf("%s should match %s with %s, got %v %v", c.path, c.pattern, c.params, n, ps)
+		}
+	}
+	for _, path := range []string{"/files/a.jpg", "/posts/Hello/07", "/posts/hello/7"} {
+		if n, _ := r.getRoute("GET", path); n != nil {
+			t.Fatalf("%s shouldn't match %s", path, n.pattern)
+		}
+	}
+
+	for _, route := range []string{"/users/:n{int}", "/users/:n{[0-9]+", "/users/:n{(}", "/a/*path{.+}"} {
+		if err := r.addRoute("GET", route, nil, nil); err == nil {
+			t.Fatalf("%s should be rejected", route)
+		}
+	}
 }
@@ -0,0 +1,241 @@
+package gee
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+//FieldError is one failed rule of one field
+type FieldError struct {
+	Field   string `json:"field"` //json, form, query, path or header name, Go name otherwise
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+//TagError is a mistake in a validate tag, such as an unknown rule or min=abc.
+//It is a bug of the server, not of the request, so AbortWithBindError
+//answers it with a 500 through the error handler.
+type TagError struct {
+	Field   string
+	Message string
+}
+
+func (e *TagError) Error() string {
+	return "gee: validate tag of field " + e.Field + ": " + e.Message
+}
+
+//ValidationErrors lists every failed field, it is what Validate returns
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Field+": "+e.Message)
+	}
+	return "gee: validation failed: " + strings.Join(msgs, "; ")
+}
+
+//Validate checks the validate tags of obj, a struct or pointer to struct.
+//Rules are comma separated: required, omitempty, min=N, max=N, len=N, email
+//and oneof=a b c. min, max and len apply to numbers by value, to strings by
+//rune count and to slices and maps by length. Zero values are checked like
+//any other, omitempty skips the rules when the field is zero and a nil
+//pointer is only checked by required. Nested structs are checked too.
+//A broken tag gives a *TagError.
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if err := validateStruct(v, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query", "path", "header"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		name := prefix + fieldName(field)
+		if field.Anonymous {
+			name = strings.TrimSuffix(prefix, ".")
+		}
+
+		if rules := field.Tag.Get("validate"); rules != "" && rules != "-" {
+			if err := validateField(fv, name, rules, errs); err != nil {
+				return err
+			}
+		}
+
+		//walk into nested structs and slices of structs
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != timeType:
+			next := name + "."
+			if field.Anonymous {
+				next = prefix
+			}
+			if err := validateStruct(fv, next, errs); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array:
+			for j := 0; j < fv.Len(); j++ {
+				elem := fv.Index(j)
+				for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+					elem = elem.Elem()
+				}
+				if elem.Kind() != reflect.Struct || elem.Type() == timeType {
+					break
+				}
+				if err := validateStruct(elem, fmt.Sprintf("%s[%d].", name, j), errs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateField(v reflect.Value, name string, rules string, errs *ValidationErrors) error {
+	fail := func(rule, param, message string) {
+		*errs = append(*errs, FieldError{Field: name, Rule: rule, Param: param, Message: message})
+	}
+
+	ruleList := strings.Split(rules, ",")
+	required, omitEmpty := false, false
+	for _, rule := range ruleList {
+		switch strings.TrimSpace(rule) {
+		case "required":
+			required = true
+		case "omitempty":
+			omitEmpty = true
+		}
+	}
+	if isZero(v) {
+		if required {
+			fail("required", "", "is required")
+			return nil
+		}
+		if omitEmpty {
+			return nil
+		}
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			//nothing to check the other rules against
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range ruleList {
+		rule = strings.TrimSpace(rule)
+		param := ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			rule, param = rule[:i], rule[i+1:]
+		}
+
+		switch rule {
+		case "", "required", "omitempty":
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return &TagError{Field: name, Message: "invalid " + rule + "=" + param}
+			}
+			size, unit, ok := sizeOf(v)
+			if !ok {
+				return &TagError{Field: name, Message: "rule " + rule + " can't be applied to " + v.Type().String()}
+			}
+			switch {
+			case rule == "min" && size < limit:
+				fail(rule, param, fmt.Sprintf("must be at least %s%s", param, unit))
+			case rule == "max" && size > limit:
+				fail(rule, param, fmt.Sprintf("must be at most %s%s", param, unit))
+			case rule == "len" && size != limit:
+				fail(rule, param, fmt.Sprintf("must be exactly %s%s", param, unit))
+			}
+		case "email":
+			if v.Kind() != reflect.String {
+				return &TagError{Field: name, Message: "rule email can't be applied to " + v.Type().String()}
+			}
+			if !emailRegexp.MatchString(v.String()) {
+				fail(rule, "", "must be a valid email address")
+			}
+		case "oneof":
+			s := fmt.Sprint(v.Interface())
+			found := false
+			for _, option := range strings.Fields(param) {
+				if s == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(rule, param, "must be one of ["+param+"]")
+			}
+		default:
+			return &TagError{Field: name, Message: "unknown rule " + rule}
+		}
+	}
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+//sizeOf is what min, max and len compare against
+func sizeOf(v reflect.Value) (size float64, unit string, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
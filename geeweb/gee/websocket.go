@@ -0,0 +1,453 @@
+package gee
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//message types, the values are the RFC 6455 opcodes
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+//close codes, see RFC 6455 section 7.4.1
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	CloseMessageTooBig    = 1009
+)
+
+const (
+	continuationFrame = 0
+	finalBit          = 0x80
+	maskBit           = 0x80
+	maxControlPayload = 125
+	wsGUID            = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultReadLimit  = 32 << 20 // 32 MB
+)
+
+var ErrWSClosed = errors.New("gee: websocket connection closed")
+
+//CloseError is returned by ReadMessage once the peer sent a close frame
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("gee: websocket closed: %d %s", e.Code, e.Text)
+}
+
+//WSConn is a WebSocket connection. One goroutine may read and any number
+//may write: messages are sent whole, one after the other, and control
+//frames like pings may go between the fragments of a NextWriter message.
+type WSConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isServer bool //servers read masked frames and write unmasked ones
+
+	//ReadLimit is the largest message ReadMessage accepts, 32 MB by default
+	ReadLimit int64
+
+	writeMu     sync.Mutex //held per frame
+	messageMu   sync.Mutex //held per data message, from NextWriter to its Close
+	closeSent   bool
+	pongHandler func(data []byte)
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, isServer bool) *WSConn {
+	return &WSConn{conn: conn, br: br, isServer: isServer, ReadLimit: defaultReadLimit}
+}
+
+//WSHandler serves an upgraded connection, it is closed once the handler returns
+type WSHandler func(c *Context, ws *WSConn)
+
+//WS registers a GET route that upgrades to WebSocket, group middlewares
+//run before the handshake so they can still reject the request
+func (group *RouterGroup) WS(pattern string, handler WSHandler) *Route {
+	return group.GET(pattern, func(c *Context) {
+		ws, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		handler(c, ws)
+	})
+}
+
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, value := range h[name] {
+		for _, s := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//sameOrigin is the default Engine.CheckWSOrigin, requests without Origin
+//are not from a browser and are let through
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || isSameOrigin(origin, r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+//Upgrade runs the server side of the RFC 6455 handshake and takes over the
+//connection. On failure the request is answered through the error handler.
+func (c *Context) Upgrade() (*WSConn, error) {
+	r := c.Req
+	fail := func(code int, message string) (*WSConn, error) {
+		err := errors.New("gee: websocket handshake: " + message)
+		c.Abort()
+		c.Error(&HTTPError{Code: code, Message: message, Err: err})
+		return nil, err
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method must be GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := sameOrigin
+	if c.engine != nil && c.engine.CheckWSOrigin != nil {
+		checkOrigin = c.engine.CheckWSOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	conn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	//only recorded for the logger, the status line is written below
+	c.Writer.WriteHeader(http.StatusSwitchingProtocols)
+	//drop the timeouts of the http server, the connection lives on its own now
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := brw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWSConn(conn, brw.Reader, true), nil
+}
+
+//SetPongHandler is called with the payload of every pong received
+func (ws *WSConn) SetPongHandler(h func(data []byte)) {
+	ws.pongHandler = h
+}
+
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WSConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+type wsFrame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (ws *WSConn) readFrame(limit int64) (*wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.br, head[:]); err != nil {
+		return nil, err
+	}
+	frame := &wsFrame{fin: head[0]&finalBit != 0, opcode: int(head[0] & 0x0f)}
+	if head[0]&0x70 != 0 {
+		return nil, ws.protocolError("reserved bits set")
+	}
+	masked := head[1]&maskBit != 0
+	if masked != ws.isServer {
+		return nil, ws.protocolError("bad masking")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return nil, ws.protocolError("invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if frame.opcode >= CloseMessage {
+		if length > maxControlPayload || !frame.fin {
+			return nil, ws.protocolError("invalid control frame")
+		}
+	} else if length > limit {
+		ws.WriteClose(CloseMessageTooBig, "")
+		return nil, fmt.Errorf("gee: websocket message exceeds %d bytes", ws.ReadLimit)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, frame.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(mask, frame.payload)
+	}
+	return frame, nil
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+func (ws *WSConn) protocolError(message string) error {
+	ws.WriteClose(CloseProtocolError, message)
+	return errors.New("gee: websocket protocol error: " + message)
+}
+
+//ReadMessage returns the next text or binary message, fragments are joined.
+//Pings are answered, pongs go to the pong handler, a close frame is
+//acknowledged and returned as *CloseError.
+func (ws *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		frame, err := ws.readFrame(ws.ReadLimit - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frame.opcode {
+		case PingMessage:
+			if err := ws.writeFrame(PongMessage, frame.payload, true); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				ws.pongHandler(frame.payload)
+			}
+			continue
+		case CloseMessage:
+			if len(frame.payload) == 1 {
+				return 0, nil, ws.protocolError("close frame with a one byte payload")
+			}
+			closeErr := &CloseError{Code: CloseNoStatusReceived}
+			if len(frame.payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(frame.payload))
+				closeErr.Text = string(frame.payload[2:])
+			}
+			ws.WriteClose(closeErr.Code, "")
+			return 0, nil, closeErr
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.protocolError("new message inside a fragmented one")
+			}
+			messageType = frame.opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.protocolError("continuation without a message")
+			}
+		default:
+			return 0, nil, ws.protocolError(fmt.Sprintf("unknown opcode %d", frame.opcode))
+		}
+
+		data = append(data, frame.payload...)
+		if frame.fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				ws.WriteClose(CloseInvalidPayload, "")
+				return 0, nil, errors.New("gee: websocket text message is not valid UTF-8")
+			}
+			if data == nil {
+				data = []byte{}
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+func (ws *WSConn) writeFrame(opcode int, payload []byte, fin bool) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWSClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	frame = append(frame, b0)
+
+	var b1 byte
+	if !ws.isServer {
+		b1 = maskBit
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, b1|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, b1|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, b1|127)
+		frame = append(frame, ext[:]...)
+	}
+
+	start := len(frame)
+	if !ws.isServer {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start = len(frame)
+		frame = append(frame, payload...)
+		maskBytes(mask, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+//WriteMessage sends data as one text or binary message
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("gee: invalid websocket message type %d", messageType)
+	}
+	ws.messageMu.Lock()
+	defer ws.messageMu.Unlock()
+	return ws.writeFrame(messageType, data, true)
+}
+
+//Ping sends a ping, the answer goes to the pong handler
+func (ws *WSConn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("gee: websocket ping payload too large")
+	}
+	return ws.writeFrame(PingMessage, data, true)
+}
+
+//WriteClose starts the closing handshake, later writes fail with ErrWSClosed.
+//CloseNoStatusReceived must not go on the wire, it sends an empty close frame.
+func (ws *WSConn) WriteClose(code int, reason string) error {
+	if code == CloseNoStatusReceived {
+		return ws.writeFrame(CloseMessage, nil, true)
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return ws.writeFrame(CloseMessage, payload, true)
+}
+
+//Close sends a normal closure if no close frame was sent yet and closes the connection
+func (ws *WSConn) Close() error {
+	ws.WriteClose(CloseNormalClosure, "")
+	return ws.conn.Close()
+}
+
+//NextWriter streams one message as fragments, every Write sends a frame
+//and Close sends the final one. Other messages wait until the writer is
+//closed, so it must always be closed.
+func (ws *WSConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("gee: invalid websocket message type %d", messageType)
+	}
+	ws.messageMu.Lock()
+	return &wsFragmentWriter{ws: ws, opcode: messageType}, nil
+}
+
+type wsFragmentWriter struct {
+	ws     *WSConn
+	opcode int
+	closed bool
+}
+
+func (w *wsFragmentWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWSClosed
+	}
+	if err := w.ws.writeFrame(w.opcode, p, false); err != nil {
+		return 0, err
+	}
+	w.opcode = continuationFrame
+	return len(p), nil
+}
+
+func (w *wsFragmentWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.ws.messageMu.Unlock()
+	return w.ws.writeFrame(w.opcode, nil, true)
+}
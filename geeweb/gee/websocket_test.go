@@ -0,0 +1,211 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//dialWS runs the client side of the handshake against server
+func dialWS(t *testing.T, server *httptest.Server, path string, header http.Header) (*WSConn, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, res
+	}
+	return newWSConn(conn, br, false), res
+}
+
+func newWSServer() *httptest.Server {
+	r := New()
+	api := r.Group("/api")
+	api.Use(func(c *Context) {
+		if c.Query("token") != "secret" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set("user", "alice")
+	})
+	api.WS("/echo", func(c *Context, ws *WSConn) {
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(data) == "whoami" {
+				data = []byte(c.GetString("user"))
+			}
+			if err := ws.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	})
+	return httptest.NewServer(r)
+}
+
+func TestWSEcho(t *testing.T) {
+	server := newWSServer()
+	defer server.Close()
+
+	ws, res := dialWS(t, server, "/api/echo?token=secret", nil)
+	if ws == nil {
+		t.Fatalf("handshake failed with %d", res.StatusCode)
+	}
+	defer ws.Close()
+	//the RFC 6455 sample key and accept value
+	if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+
+	if err := ws.WriteMessage(TextMessage, []byte("whoami")); err != nil {
+		t.Fatal(err)
+	}
+	if mt, data, err := ws.ReadMessage(); err != nil || mt != TextMessage || string(data) != "alice" {
+		t.Fatalf("got %d %q %v", mt, data, err)
+	}
+
+	large := bytes.Repeat([]byte{0xfe}, 70000)
+	if err := ws.WriteMessage(BinaryMessage, large); err != nil {
+		t.Fatal(err)
+	}
+	if mt, data, err := ws.ReadMessage(); err != nil || mt != BinaryMessage || !bytes.Equal(data, large) {
+		t.Fatalf("got %d, %d bytes, %v", mt, len(data), err)
+	}
+}
+
+func TestWSFragmentsAndControlFrames(t *testing.T) {
+	server := newWSServer()
+	defer server.Close()
+	ws, res := dialWS(t, server, "/api/echo?token=secret", nil)
+	if ws == nil {
+		t.Fatalf("handshake failed with %d", res.StatusCode)
+	}
+
+	pongs := make(chan string, 1)
+	ws.SetPongHandler(func(data []byte) { pongs <- string(data) })
+
+	w, _ := ws.NextWriter(TextMessage)
+	w.Write([]byte("hello, "))
+	//a ping between fragments is answered right away
+	if err := ws.Ping([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("world"))
+	w.Close()
+
+	if mt, data, err := ws.ReadMessage(); err != nil || mt != TextMessage || string(data) != "hello, world" {
+		t.Fatalf("got %d %q %v", mt, data, err)
+	}
+	if got := <-pongs; got != "ping" {
+		t.Fatalf("expected pong with ping, got %q", got)
+	}
+
+	ws.WriteClose(CloseGoingAway, "bye")
+	_, _, err := ws.ReadMessage()
+	if ce, ok := err.(*CloseError); !ok || ce.Code != CloseGoingAway {
+		t.Fatalf("expected the close frame to be echoed, got %v", err)
+	}
+	ws.Close()
+}
+
+func TestWSConcurrentMessages(t *testing.T) {
+	server := newWSServer()
+	defer server.Close()
+	ws, res := dialWS(t, server, "/api/echo?token=secret", nil)
+	if ws == nil {
+		t.Fatalf("handshake failed with %d", res.StatusCode)
+	}
+	defer ws.Close()
+
+	w, _ := ws.NextWriter(TextMessage)
+	w.Write([]byte("first "))
+	done := make(chan error)
+	go func() { done <- ws.WriteMessage(TextMessage, []byte("second")) }()
+	//the other message must not land between the fragments
+	time.Sleep(20 * time.Millisecond)
+	w.Write([]byte("message"))
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{"first message", "second"} {
+		if _, data, err := ws.ReadMessage(); err != nil || string(data) != expect {
+			t.Fatalf("expect %q, got %q %v", expect, data, err)
+		}
+	}
+}
+
+func TestWSClosePayload(t *testing.T) {
+	server := newWSServer()
+	defer server.Close()
+
+	//an empty close is echoed empty, 1005 never goes on the wire,
+	//and a one byte payload is a protocol error
+	for payload, expect := range map[string][]byte{"": {}, "\x03": {0x03, 0xea}} {
+		ws, res := dialWS(t, server, "/api/echo?token=secret", nil)
+		if ws == nil {
+			t.Fatalf("handshake failed with %d", res.StatusCode)
+		}
+		if err := ws.writeFrame(CloseMessage, []byte(payload), true); err != nil {
+			t.Fatal(err)
+		}
+		frame, err := ws.readFrame(ws.ReadLimit)
+		if err != nil || frame.opcode != CloseMessage || !bytes.Equal(frame.payload[:len(expect)], expect) ||
+			(len(expect) == 0 && len(frame.payload) != 0) {
+			t.Fatalf("close payload %q: got %v %x", payload, err, frame.payload)
+		}
+		ws.conn.Close()
+	}
+}
+
+func TestWSHandshakeRejected(t *testing.T) {
+	server := newWSServer()
+	defer server.Close()
+
+	if ws, res := dialWS(t, server, "/api/echo", nil); ws != nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("middleware should reject before the upgrade, got %d", res.StatusCode)
+	}
+	header := http.Header{"Origin": {"http://evil.example"}}
+	if ws, res := dialWS(t, server, "/api/echo?token=secret", header); ws != nil || res.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross origin handshake should be refused, got %d", res.StatusCode)
+	}
+	header = http.Header{"Sec-Websocket-Version": {"8"}}
+	if ws, res := dialWS(t, server, "/api/echo?token=secret", header); ws != nil || res.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("old versions should get 426, got %d", res.StatusCode)
+	}
+
+	res, err := http.Get(server.URL + "/api/echo?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET should get 400, got %d", res.StatusCode)
+	}
+}
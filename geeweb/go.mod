@@ -1,3 +1,9 @@
 module geeweb
 
 go 1.14
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
+)